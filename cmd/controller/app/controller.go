@@ -13,13 +13,35 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 
 	patroniClusterController := cluster.NewPatroniClusterController(
 		client.Kubernetes(),
+		client.Config(),
 		client.PgOperator(),
 		informerFactory.PgOperatorInformerFactory().Rccp().V1alpha1().PatroniClusters(),
+		informerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets(),
+		mgrConfig,
+	)
+
+	patroniBackupController := cluster.NewPatroniBackupController(
+		client.Kubernetes(),
+		client.Config(),
+		client.PgOperator(),
+		informerFactory.PgOperatorInformerFactory().Rccp().V1alpha1().PatroniBackups(),
+		informerFactory.PgOperatorInformerFactory().Rccp().V1alpha1().PatroniClusters(),
+		mgrConfig,
+	)
+
+	patroniBackupScheduleController := cluster.NewPatroniBackupScheduleController(
+		client.Kubernetes(),
+		client.Config(),
+		client.PgOperator(),
+		informerFactory.PgOperatorInformerFactory().Rccp().V1alpha1().PatroniClusters(),
+		informerFactory.PgOperatorInformerFactory().Rccp().V1alpha1().PatroniBackups(),
 		mgrConfig,
 	)
 
 	controllers := map[string]manager.Runnable{
-		"patroni-cluster-controller": patroniClusterController,
+		"patroni-cluster-controller":         patroniClusterController,
+		"patroni-backup-controller":          patroniBackupController,
+		"patroni-backup-schedule-controller": patroniBackupScheduleController,
 	}
 
 	for name, ctrl := range controllers {
@@ -9,6 +9,7 @@ import (
 	"os"
 	"pgoperator/pkg/constants"
 	"pgoperator/pkg/simple/client/k8s"
+	"pgoperator/pkg/simple/client/patroni"
 	"strings"
 )
 
@@ -16,11 +17,15 @@ import (
 type Config struct {
 	// 指定kubernetes集群的配置文件，None时使用容器内的配置
 	KubernetesOptions *k8s.KubernetesOptions `yaml:"kubernetes"`
+
+	// Patroni REST API 访问和健康检查配置
+	PatroniOptions *patroni.Options `yaml:"patroni"`
 }
 
 func New() *Config {
 	s := &Config{
 		KubernetesOptions: k8s.NewKubernetesOptions(),
+		PatroniOptions:    patroni.NewPatroniOptions(),
 	}
 	return s
 }
@@ -29,6 +34,7 @@ func New() *Config {
 func (c *Config) Validate() []error {
 	var errs []error
 	errs = append(errs, c.KubernetesOptions.Validate()...)
+	errs = append(errs, c.PatroniOptions.Validate()...)
 	return errs
 }
 
@@ -36,6 +42,7 @@ func (c *Config) Flags() cliflag.NamedFlagSets {
 	fss := cliflag.NamedFlagSets{}
 
 	c.KubernetesOptions.AddFlags(fss.FlagSet("kubernetes"), c.KubernetesOptions)
+	c.PatroniOptions.AddFlags(fss.FlagSet("patroni"), c.PatroniOptions)
 
 	kfs := fss.FlagSet("klog")
 	local := flag.NewFlagSet("klog", flag.ExitOnError)
@@ -68,6 +75,13 @@ func TryLoadFromDisk() (*Config, error) {
 		opt.KubernetesOptions = &k8s.KubernetesOptions{}
 	}
 
+	// 配置文件中未设置的字段使用默认值
+	patroniOptions := patroni.NewPatroniOptions()
+	if opt.PatroniOptions != nil {
+		opt.PatroniOptions.ApplyTo(patroniOptions)
+	}
+	opt.PatroniOptions = patroniOptions
+
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,73 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed
+type BackupPhase string
+
+const (
+	BackupPending   BackupPhase = "Pending"
+	BackupRunning   BackupPhase = "Running"
+	BackupCompleted BackupPhase = "Completed"
+	BackupFailed    BackupPhase = "Failed"
+)
+
+// +kubebuilder:validation:Enum=Full;Incremental
+type BackupType string
+
+const (
+	BackupFull BackupType = "Full"
+	// BackupIncremental 基于最近的备份创建增量备份，没有可用的基础备份时创建完整备份
+	BackupIncremental BackupType = "Incremental"
+)
+
+// +genclient
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Member",type="string",JSONPath=".status.member",priority=1
+// +kubebuilder:printcolumn:name="Size",type="string",JSONPath=".status.size"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PatroniBackup 集群的一次基础备份，备份写入集群 backupStorage 配置的对象存储
+type PatroniBackup struct {
+	metav1.TypeMeta     `json:",inline"`
+	metav1.ObjectMeta   `json:"metadata,omitempty"`
+	PatroniBackupSpec   PatroniBackupSpec   `json:"spec"`
+	PatroniBackupStatus PatroniBackupStatus `json:"status,omitempty"`
+}
+
+type PatroniBackupSpec struct {
+	// 同一命名空间中的 PatroniCluster 名称
+	ClusterName string `json:"clusterName"`
+	// +kubebuilder:default=Full
+	Type BackupType `json:"type,omitempty"`
+}
+
+type PatroniBackupStatus struct {
+	Phase BackupPhase `json:"phase,omitempty"`
+	// 执行备份的成员 Pod，优先选择健康的 replica
+	Member string `json:"member,omitempty"`
+	// 对象存储中的备份名称
+	BackupName     string       `json:"backupName,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// 压缩后的备份大小
+	Size      string `json:"size,omitempty"`
+	SizeBytes int64  `json:"sizeBytes,omitempty"`
+	StartLSN  string `json:"startLSN,omitempty"`
+	EndLSN    string `json:"endLSN,omitempty"`
+	// 恢复该备份需要的 WAL 范围
+	BeginWAL string `json:"beginWAL,omitempty"`
+	EndWAL   string `json:"endWAL,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PatroniBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []*PatroniBackup `json:"items"`
+}
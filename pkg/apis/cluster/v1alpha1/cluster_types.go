@@ -1,17 +1,70 @@
 package v1alpha1
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
-// +kubebuilder:validation:Enum=Initialized;Runing
+// +kubebuilder:validation:Enum=Initialized;Running;Runing
 type ClusterStatus string
 
 const (
 	ClusterInit    ClusterStatus = "Initialized"
-	ClusterRunning ClusterStatus = "Runing"
+	ClusterRunning ClusterStatus = "Running"
+	// ClusterRunningLegacy 早期版本拼写错误的状态值，保留在枚举中以便已有对象通过校验，控制器会将其修正为 Running
+	ClusterRunningLegacy ClusterStatus = "Runing"
+)
+
+// 集群状态条件类型
+const (
+	// ConditionAvailable 集群存在就绪的 leader，可以提供读写服务
+	ConditionAvailable = "Available"
+	// ConditionProgressing 集群正在初始化或滚动更新
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded 存在未就绪或状态异常的成员
+	ConditionDegraded = "Degraded"
+	// ConditionBackupHealthy 定时备份按时执行且最近的备份成功
+	ConditionBackupHealthy = "BackupHealthy"
+	// ConditionScaleInBlocked 缩容无法继续，消息中说明原因，缩容可以继续或完成后移除
+	ConditionScaleInBlocked = "ScaleInBlocked"
+)
+
+// +kubebuilder:validation:Enum=Retain;Delete
+type VolumeReclaimPolicy string
+
+const (
+	// VolumeRetain 删除集群时保留成员数据卷
+	VolumeRetain VolumeReclaimPolicy = "Retain"
+	// VolumeDelete 删除集群时同时删除成员数据卷
+	VolumeDelete VolumeReclaimPolicy = "Delete"
+)
+
+// +kubebuilder:validation:Enum=UpgradingReplicas;Switchover;UpgradingLeader;Completed
+type UpgradePhase string
+
+const (
+	UpgradeReplicas   UpgradePhase = "UpgradingReplicas"
+	UpgradeSwitchover UpgradePhase = "Switchover"
+	UpgradeLeader     UpgradePhase = "UpgradingLeader"
+	UpgradeCompleted  UpgradePhase = "Completed"
 )
 
+// RotateCredentialsAnnotation 设置后控制器为自动生成的 Secret 重新生成密码，
+// 可选值为 all、superuser、replication
+const RotateCredentialsAnnotation = "rccp.ruijie.com.cn/rotate-credentials"
+
 // +genclient
+// +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.status"
+// +kubebuilder:printcolumn:name="Leader",type="string",JSONPath=".status.leader"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.readyMembers"
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image",priority=1
+// +kubebuilder:printcolumn:name="Sync",type="string",JSONPath=".spec.synchronousMode",priority=1
+// +kubebuilder:printcolumn:name="Upgrade",type="string",JSONPath=".status.upgrade.phase"
+// +kubebuilder:printcolumn:name="Upgraded",type="string",JSONPath=".status.upgrade.progress"
+// +kubebuilder:printcolumn:name="NextMaintenance",type="date",JSONPath=".status.pendingMaintenance.nextWindow",priority=1
+// +kubebuilder:printcolumn:name="Standby",type="string",JSONPath=".status.standby.phase",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -23,19 +76,471 @@ type PatroniCluster struct {
 }
 
 type PatroniClusterSpec struct {
+	// 集群成员列表，每个成员对应一个 statefulset，增删成员即可扩缩容
+	// +kubebuilder:validation:MinItems=1
 	NodeList               []string `json:"nodeList"`
 	Image                  string   `json:"image"`
 	ServiceAccount         string   `json:"serviceAccount,omitempty"`
 	RequirePodAntiAffinity bool     `json:"requirePodAntiAffinity,omitempty"`
-	// TODO: 用户password可配置
+	// 用户密码保存在同一命名空间 Secret 的 password 键中，未指定 Secret 时由控制器自动生成
 	SuperUserName             string `json:"superUserName,omitempty"`
 	SuperUserSecretName       string `json:"superUserSecretName,omitempty"`
 	ReplicationUserName       string `json:"replicationUserName,omitempty"`
 	ReplicationUserSecretName string `json:"replicationUserSecretName,omitempty"`
+	// 删除集群时数据卷的回收策略，默认保留
+	// +kubebuilder:default=Retain
+	VolumeReclaimPolicy VolumeReclaimPolicy `json:"volumeReclaimPolicy,omitempty"`
+	// 一次允许从 NodeList 中移除的最大成员数，超出时拒绝缩容
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	MaxScaleInMembers int32 `json:"maxScaleInMembers,omitempty"`
+	// 成员调度配置，name 对应 NodeList 中的成员，用于将成员固定到指定节点（如使用本地 PV 时）
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Members []MemberSpec `json:"members,omitempty"`
+	// 成员数据卷配置，已有成员只支持扩容，其他参数只在创建成员时生效
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+	// postgres 容器的资源请求和限制，未设置 shared_buffers、effective_cache_size 时根据内存限制计算
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// +optional
+	Postgresql *PostgresqlSpec `json:"postgresql,omitempty"`
+	// Patroni 动态配置，集群运行后通过 Patroni REST API 应用
+	// +optional
+	Patroni *PatroniSpec `json:"patroni,omitempty"`
+	// 复制模式，通过 Patroni 动态配置统一设置
+	// +kubebuilder:default=off
+	SynchronousMode SynchronousMode `json:"synchronousMode,omitempty"`
+	// 同步 replica 数量，只在同步复制模式下生效
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	SynchronousNodeCount int32 `json:"synchronousNodeCount,omitempty"`
+	// 手动切换请求，控制器执行后清除，结果记录在 status.lastSwitchover 和事件中
+	// +optional
+	Switchover *SwitchoverSpec `json:"switchover,omitempty"`
+	// 维护窗口，配置后控制器发起的滚动重启、升级和切换只在窗口内执行，未配置时不限制
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// 备份存储，配置后成员持续归档 WAL，并支持通过 PatroniBackup 创建基础备份，镜像中需要包含 wal-g
+	// +optional
+	BackupStorage *BackupStorageSpec `json:"backupStorage,omitempty"`
+	// 定时备份和备份保留策略，需要同时配置 backupStorage
+	// +optional
+	BackupSchedule *BackupScheduleSpec `json:"backupSchedule,omitempty"`
+	// 集群初始化方式，只在集群首次创建时生效，未配置时通过 initdb 创建空集群
+	// +optional
+	Bootstrap *BootstrapSpec `json:"bootstrap,omitempty"`
+	// 作为远端主库的备库集群运行，只能在创建集群时配置，删除后提升为独立的主集群
+	// +optional
+	Standby *StandbySpec `json:"standby,omitempty"`
+}
+
+// StandbySpec Patroni standby_cluster 配置：standby leader 从远端主库复制，其余成员从 standby leader 复制。
+// 复制使用本集群的复制用户，用户名和密码需要与源集群一致；host 和 clusterName 需要指定其一
+type StandbySpec struct {
+	// 远端主库地址
+	// +optional
+	Host string `json:"host,omitempty"`
+	// +kubebuilder:default=5432
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// 源 PatroniCluster 名称，通过其 primary 服务复制
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+	// 源集群所在命名空间，默认与本集群相同
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// 从归档获取 WAL 的命令，例如 wal-g wal-fetch %f %p，流复制中断或落后过多时使用
+	// +optional
+	RestoreCommand string `json:"restoreCommand,omitempty"`
+	// 源集群上使用的复制槽
+	// +optional
+	PrimarySlotName string `json:"primarySlotName,omitempty"`
+}
+
+// BootstrapSpec 通过 Patroni 自定义 bootstrap 初始化第一个成员，其余成员在它成为 leader 后从它复制，
+// recovery 和 cloneFrom 最多指定一个
+type BootstrapSpec struct {
+	// 从基础备份恢复并重放归档 WAL
+	// +optional
+	Recovery *RecoverySpec `json:"recovery,omitempty"`
+	// 通过 pg_basebackup 从运行中的集群复制数据
+	// +optional
+	CloneFrom *CloneSpec `json:"cloneFrom,omitempty"`
+}
+
+// CloneSpec 复制的源集群，使用源集群的复制用户连接，复制完成后新集群使用自己的用户和服务，与源集群相互独立
+type CloneSpec struct {
+	ClusterName string `json:"clusterName"`
+	// 源集群所在命名空间，默认与新集群相同
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// 从源集群的 replica 复制以避免增加 leader 的负载，源集群需要有就绪的 replica
+	// +optional
+	FromReplica bool `json:"fromReplica,omitempty"`
+}
+
+// RecoverySpec 恢复使用的备份和恢复目标，backup 和 source 需要指定其一，恢复目标最多指定一个，
+// 未指定恢复目标时重放全部归档 WAL
+type RecoverySpec struct {
+	// 同一命名空间中已完成的 PatroniBackup 名称，控制器据此填充 source 和 backupName
+	// +optional
+	Backup string `json:"backup,omitempty"`
+	// 备份所在的对象存储，path 为源集群的备份路径，例如 <namespace>/<cluster>
+	// +optional
+	Source *S3Storage `json:"source,omitempty"`
+	// 对象存储中的备份名称，默认使用最新的备份
+	// +optional
+	BackupName string `json:"backupName,omitempty"`
+	// 恢复到指定时间点
+	// +optional
+	TargetTime *metav1.Time `json:"targetTime,omitempty"`
+	// 恢复到指定 LSN，例如 0/3000060
+	// +kubebuilder:validation:Pattern=`^[0-9A-Fa-f]+/[0-9A-Fa-f]+$`
+	// +optional
+	TargetLSN string `json:"targetLSN,omitempty"`
+	// 恢复到 pg_create_restore_point 创建的还原点
+	// +optional
+	TargetName string `json:"targetName,omitempty"`
+}
+
+// BackupScheduleSpec 定时创建 PatroniBackup 并按保留策略清理过期备份
+type BackupScheduleSpec struct {
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Schedules []BackupSchedule `json:"schedules"`
+	// 未配置时不清理备份
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+	// 超过调度时间该秒数仍未开始的备份视为延迟，默认 3600
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// 暂停创建定时备份，已有备份仍按保留策略清理
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// BackupSchedule 一个备份调度
+type BackupSchedule struct {
+	// 调度名称，用于备份对象的命名和标签
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=20
+	Name string `json:"name"`
+	// 标准 5 字段 cron 表达式，按 UTC 时间计算，例如 "0 1 * * *"
+	Schedule string `json:"schedule"`
+	// +kubebuilder:default=Full
+	Type BackupType `json:"type,omitempty"`
+}
+
+// BackupRetention 备份保留策略，满足任一规则的完整备份会被保留，增量备份随其之前的完整备份一起清理，
+// 只清理定时创建的备份，最近一个完成的完整备份始终保留
+type BackupRetention struct {
+	// 保留最近的完整备份数量
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FullBackups *int32 `json:"fullBackups,omitempty"`
+	// 保留最近天数内的完整备份
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Days *int32 `json:"days,omitempty"`
+}
+
+// BackupStorageSpec WAL 归档和基础备份使用的对象存储
+type BackupStorageSpec struct {
+	S3 S3Storage `json:"s3"`
+	// 强制切换 WAL 段文件的间隔，单位为秒，默认 60
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ArchiveTimeout *int32 `json:"archiveTimeout,omitempty"`
+}
+
+// S3Storage S3 兼容的对象存储，例如 MinIO
+type S3Storage struct {
+	// 对象存储地址，例如 http://minio.minio:9000，未指定时使用 AWS S3
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket"`
+	// 备份在 bucket 中的路径，默认 <namespace>/<cluster>
+	// +optional
+	Path string `json:"path,omitempty"`
+	// +kubebuilder:default=us-east-1
+	Region string `json:"region,omitempty"`
+	// 使用 path-style 访问 bucket，MinIO 需要开启
+	// +optional
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+	// 同一命名空间中保存访问密钥的 Secret，包含 accessKeyId 和 secretAccessKey 键
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// +kubebuilder:validation:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+type Weekday string
+
+// MaintenanceWindow 每周固定的维护时间段，结束时间早于开始时间表示跨越零点
+type MaintenanceWindow struct {
+	// 窗口开始的星期，未指定时每天生效
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+	// 开始时间，格式为 HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	StartTime string `json:"startTime"`
+	// 结束时间，格式为 HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	EndTime string `json:"endTime"`
+	// IANA 时区名称，例如 Asia/Shanghai，默认 UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// SwitchoverSpec 手动切换 leader 的请求
+type SwitchoverSpec struct {
+	// 切换目标成员，可以是 NodeList 中的名称或成员 Pod 名称，未指定时选择健康的 replica
+	// +optional
+	Candidate string `json:"candidate,omitempty"`
+	// 计划执行时间，未指定时立即执行
+	// +optional
+	ScheduledAt *metav1.Time `json:"scheduledAt,omitempty"`
+	// 使用 failover 代替 switchover，用于 leader 不可用的场景
+	// +optional
+	Failover bool `json:"failover,omitempty"`
+}
+
+// PostgresqlSpec PostgreSQL 配置，集群运行后通过 Patroni 动态配置应用，需要重启的参数记录在成员状态中
+type PostgresqlSpec struct {
+	// PostgreSQL 参数，优先于控制器计算的参数
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// 追加在默认规则之前的 pg_hba 规则
+	// +optional
+	PgHba []string `json:"pgHba,omitempty"`
+}
+
+// PatroniSpec Patroni 动态配置，未设置的字段使用 Patroni 默认值
+type PatroniSpec struct {
+	// leader 锁的有效期，单位为秒
+	// +kubebuilder:validation:Minimum=20
+	// +optional
+	TTL *int32 `json:"ttl,omitempty"`
+	// HA 循环的间隔，单位为秒
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	LoopWait *int32 `json:"loopWait,omitempty"`
+	// DCS 和 PostgreSQL 操作的重试超时，单位为秒
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RetryTimeout *int32 `json:"retryTimeout,omitempty"`
+	// 复制延迟超过该值（字节）的 replica 不参与故障切换
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaximumLagOnFailover *int64 `json:"maximumLagOnFailover,omitempty"`
+	// leader Pod 的 role 标签值，primary 服务据此选择 leader，默认 master。
+	// Patroni 3.0.3 之前的版本不支持修改，始终使用 master
+	// +kubebuilder:validation:Enum=master;primary
+	// +optional
+	LeaderLabelValue string `json:"leaderLabelValue,omitempty"`
+}
+
+// VolumeSpec 成员数据卷的声明参数
+type VolumeSpec struct {
+	// 数据卷容量，默认 5Gi
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+	// 未指定时使用默认 StorageClass
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// 默认 ReadWriteOnce
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// 用于绑定预先创建的 PV（如本地 PV）
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// StorageSpec 数据目录和 WAL 的数据卷配置
+type StorageSpec struct {
+	VolumeSpec `json:",inline"`
+	// 独立的 WAL 数据卷，配置后 pg_wal 位于该数据卷中，未配置时与数据目录共用数据卷
+	// +optional
+	WAL *VolumeSpec `json:"wal,omitempty"`
+}
+
+// MemberSpec 单个成员的调度约束
+type MemberSpec struct {
+	// NodeList 中的成员名称
+	Name string `json:"name"`
+	// 成员固定运行的节点名称
+	NodeName string `json:"nodeName,omitempty"`
+	// 成员 Pod 的节点选择器
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 type PatroniClusterStatus struct {
-	Status ClusterStatus `json:"status,omitempty"`
+	Status             ClusterStatus `json:"status,omitempty"`
+	ObservedGeneration int64         `json:"observedGeneration,omitempty"`
+	// 当前 leader 成员名称
+	Leader string `json:"leader,omitempty"`
+	// 当前的同步 replica
+	SyncStandby []string `json:"syncStandby,omitempty"`
+	// 就绪成员数，格式为 就绪成员数/成员总数
+	ReadyMembers string `json:"readyMembers,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	Members []MemberStatus `json:"members,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	Upgrade     *UpgradeStatus    `json:"upgrade,omitempty"`
+	Credentials *CredentialStatus `json:"credentials,omitempty"`
+	Services    *ServiceStatus    `json:"services,omitempty"`
+	// 数据卷扩容进度
+	Resize *ResizeStatus `json:"resize,omitempty"`
+	// 最近一次手动切换的结果
+	LastSwitchover *SwitchoverStatus `json:"lastSwitchover,omitempty"`
+	// 等待维护窗口执行的操作
+	PendingMaintenance *MaintenanceStatus `json:"pendingMaintenance,omitempty"`
+	// 定时备份的调度状态
+	// +listType=map
+	// +listMapKey=name
+	BackupSchedules []BackupScheduleStatus `json:"backupSchedules,omitempty"`
+	// 备库集群的复制状态，提升完成后清除
+	Standby *StandbyStatus `json:"standby,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Following;Waiting;Promoting
+type StandbyPhase string
+
+const (
+	StandbyFollowing StandbyPhase = "Following"
+	// 已经删除 spec.standby，等待 standby leader 健康并追平源集群后提升
+	StandbyWaiting   StandbyPhase = "Waiting"
+	StandbyPromoting StandbyPhase = "Promoting"
+)
+
+// StandbyStatus 备库集群的状态
+type StandbyStatus struct {
+	Phase StandbyPhase `json:"phase,omitempty"`
+	// 复制源地址，格式为 host:port
+	Source string `json:"source,omitempty"`
+	// 源 PatroniCluster，格式为 namespace/name，用于在提升前计算复制延迟
+	SourceCluster string `json:"sourceCluster,omitempty"`
+	// 从远端复制的 standby leader 成员
+	Leader string `json:"leader,omitempty"`
+	// standby leader 回放位置落后源集群 leader 的字节数，源集群不是 PatroniCluster 或无法访问时为空
+	Lag *int64 `json:"lag,omitempty"`
+	// 开始提升的时间
+	PromotionTime *metav1.Time `json:"promotionTime,omitempty"`
+}
+
+// BackupScheduleStatus 单个备份调度的状态
+type BackupScheduleStatus struct {
+	Name string `json:"name"`
+	// 最近一次调度创建的 PatroniBackup
+	LastBackup       string       `json:"lastBackup,omitempty"`
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// ServiceStatus 应用访问集群使用的服务地址
+type ServiceStatus struct {
+	// 读写服务，选择 leader
+	Primary string `json:"primary,omitempty"`
+	// 只读服务，选择所有 replica
+	Replicas string `json:"replicas,omitempty"`
+	// 成员之间复制使用的 headless 服务
+	Headless string `json:"headless,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Resizing;FileSystemResizePending;Completed;Failed
+type ResizePhase string
+
+const (
+	ResizeInProgress ResizePhase = "Resizing"
+	// ResizeFileSystemPending 存储驱动不支持在线扩容文件系统，需要重启成员 Pod 完成扩容
+	ResizeFileSystemPending ResizePhase = "FileSystemResizePending"
+	ResizeCompleted         ResizePhase = "Completed"
+	ResizeFailed            ResizePhase = "Failed"
+)
+
+// ResizeStatus 成员数据卷扩容进度
+type ResizeStatus struct {
+	Phase ResizePhase `json:"phase,omitempty"`
+	// 尚未完成扩容的 PVC
+	PendingVolumes []string     `json:"pendingVolumes,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=off;synchronous;strict
+type SynchronousMode string
+
+const (
+	SynchronousOff SynchronousMode = "off"
+	// SynchronousOn 同步复制，没有可用的同步 replica 时 leader 退化为异步复制
+	SynchronousOn SynchronousMode = "synchronous"
+	// SynchronousStrict 严格同步复制，没有可用的同步 replica 时 leader 停止写入
+	SynchronousStrict SynchronousMode = "strict"
+)
+
+// SwitchoverStatus 最近一次手动切换的结果
+type SwitchoverStatus struct {
+	// switchover 或 failover
+	Type string `json:"type,omitempty"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Succeeded 或 Failed
+	Result  string      `json:"result,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Time    metav1.Time `json:"time,omitempty"`
+}
+
+// MaintenanceStatus 等待维护窗口执行的操作
+type MaintenanceStatus struct {
+	Pending []string `json:"pending,omitempty"`
+	// 下一个维护窗口的开始时间
+	NextWindow *metav1.Time `json:"nextWindow,omitempty"`
+}
+
+// CredentialStatus 已经应用到数据库的用户密码摘要，用于检测 Secret 内容变化
+type CredentialStatus struct {
+	SuperUserHash       string       `json:"superUserHash,omitempty"`
+	ReplicationUserHash string       `json:"replicationUserHash,omitempty"`
+	LastRotationTime    *metav1.Time `json:"lastRotationTime,omitempty"`
+	// Secret 中的密码已经变化但尚未应用到数据库，备库集群在提升为主集群后才会修改密码
+	RotationPending bool `json:"rotationPending,omitempty"`
+}
+
+// MemberStatus 集群成员状态，角色、时间线和复制延迟来自 Patroni REST API
+type MemberStatus struct {
+	// 成员 Pod 名称，同时也是 Patroni 成员名称
+	Name string `json:"name"`
+	Node string `json:"node,omitempty"`
+	// leader、replica、sync_standby 或 standby_leader
+	Role string `json:"role,omitempty"`
+	// Patroni 成员状态，例如 running、starting、start failed
+	State    string `json:"state,omitempty"`
+	Timeline int64  `json:"timeline,omitempty"`
+	// 复制延迟，单位为字节
+	Lag   *int64 `json:"lag,omitempty"`
+	Ready bool   `json:"ready"`
+	// 修改的参数需要重启 PostgreSQL 才能生效
+	PendingRestart bool `json:"pendingRestart,omitempty"`
+}
+
+// UpgradeStatus 镜像升级进度
+type UpgradeStatus struct {
+	Phase       UpgradePhase `json:"phase,omitempty"`
+	TargetImage string       `json:"targetImage,omitempty"`
+	// 已经运行目标镜像的成员
+	UpgradedMembers []string `json:"upgradedMembers,omitempty"`
+	// 升级进度，格式为 已升级成员数/成员总数
+	Progress       string       `json:"progress,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
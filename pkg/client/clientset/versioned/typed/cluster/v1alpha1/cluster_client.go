@@ -28,6 +28,7 @@ import (
 
 type RccpV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	PatroniBackupsGetter
 	PatroniClustersGetter
 }
 
@@ -36,6 +37,10 @@ type RccpV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *RccpV1alpha1Client) PatroniBackups(namespace string) PatroniBackupInterface {
+	return newPatroniBackups(c, namespace)
+}
+
 func (c *RccpV1alpha1Client) PatroniClusters(namespace string) PatroniClusterInterface {
 	return newPatroniClusters(c, namespace)
 }
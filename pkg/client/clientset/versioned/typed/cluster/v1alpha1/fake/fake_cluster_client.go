@@ -29,6 +29,10 @@ type FakeRccpV1alpha1 struct {
 	*testing.Fake
 }
 
+func (c *FakeRccpV1alpha1) PatroniBackups(namespace string) v1alpha1.PatroniBackupInterface {
+	return &FakePatroniBackups{c, namespace}
+}
+
 func (c *FakeRccpV1alpha1) PatroniClusters(namespace string) v1alpha1.PatroniClusterInterface {
 	return &FakePatroniClusters{c, namespace}
 }
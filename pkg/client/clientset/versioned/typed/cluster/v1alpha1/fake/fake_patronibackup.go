@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The RUIJIE Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+	v1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakePatroniBackups implements PatroniBackupInterface
+type FakePatroniBackups struct {
+	Fake *FakeRccpV1alpha1
+	ns   string
+}
+
+var patronibackupsResource = schema.GroupVersionResource{Group: "rccp.ruijie.com.cn", Version: "v1alpha1", Resource: "patronibackups"}
+
+var patronibackupsKind = schema.GroupVersionKind{Group: "rccp.ruijie.com.cn", Version: "v1alpha1", Kind: "PatroniBackup"}
+
+// Get takes name of the patroniBackup, and returns the corresponding patroniBackup object, and an error if there is any.
+func (c *FakePatroniBackups) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PatroniBackup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(patronibackupsResource, c.ns, name), &v1alpha1.PatroniBackup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PatroniBackup), err
+}
+
+// List takes label and field selectors, and returns the list of PatroniBackups that match those selectors.
+func (c *FakePatroniBackups) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PatroniBackupList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(patronibackupsResource, patronibackupsKind, c.ns, opts), &v1alpha1.PatroniBackupList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.PatroniBackupList{ListMeta: obj.(*v1alpha1.PatroniBackupList).ListMeta}
+	for _, item := range obj.(*v1alpha1.PatroniBackupList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested patroniBackups.
+func (c *FakePatroniBackups) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(patronibackupsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a patroniBackup and creates it.  Returns the server's representation of the patroniBackup, and an error, if there is any.
+func (c *FakePatroniBackups) Create(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.CreateOptions) (result *v1alpha1.PatroniBackup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(patronibackupsResource, c.ns, patroniBackup), &v1alpha1.PatroniBackup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PatroniBackup), err
+}
+
+// Update takes the representation of a patroniBackup and updates it. Returns the server's representation of the patroniBackup, and an error, if there is any.
+func (c *FakePatroniBackups) Update(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.UpdateOptions) (result *v1alpha1.PatroniBackup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(patronibackupsResource, c.ns, patroniBackup), &v1alpha1.PatroniBackup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PatroniBackup), err
+}
+
+// Delete takes name of the patroniBackup and deletes it. Returns an error if one occurs.
+func (c *FakePatroniBackups) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(patronibackupsResource, c.ns, name, opts), &v1alpha1.PatroniBackup{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePatroniBackups) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(patronibackupsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.PatroniBackupList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched patroniBackup.
+func (c *FakePatroniBackups) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PatroniBackup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(patronibackupsResource, c.ns, name, pt, data, subresources...), &v1alpha1.PatroniBackup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.PatroniBackup), err
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The RUIJIE Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	v1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	scheme "pgoperator/pkg/client/clientset/versioned/scheme"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PatroniBackupsGetter has a method to return a PatroniBackupInterface.
+// A group's client should implement this interface.
+type PatroniBackupsGetter interface {
+	PatroniBackups(namespace string) PatroniBackupInterface
+}
+
+// PatroniBackupInterface has methods to work with PatroniBackup resources.
+type PatroniBackupInterface interface {
+	Create(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.CreateOptions) (*v1alpha1.PatroniBackup, error)
+	Update(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.UpdateOptions) (*v1alpha1.PatroniBackup, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.PatroniBackup, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.PatroniBackupList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PatroniBackup, err error)
+	PatroniBackupExpansion
+}
+
+// patroniBackups implements PatroniBackupInterface
+type patroniBackups struct {
+	client rest.Interface
+	ns     string
+}
+
+// newPatroniBackups returns a PatroniBackups
+func newPatroniBackups(c *RccpV1alpha1Client, namespace string) *patroniBackups {
+	return &patroniBackups{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the patroniBackup, and returns the corresponding patroniBackup object, and an error if there is any.
+func (c *patroniBackups) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.PatroniBackup, err error) {
+	result = &v1alpha1.PatroniBackup{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PatroniBackups that match those selectors.
+func (c *patroniBackups) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.PatroniBackupList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.PatroniBackupList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested patroniBackups.
+func (c *patroniBackups) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a patroniBackup and creates it.  Returns the server's representation of the patroniBackup, and an error, if there is any.
+func (c *patroniBackups) Create(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.CreateOptions) (result *v1alpha1.PatroniBackup, err error) {
+	result = &v1alpha1.PatroniBackup{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(patroniBackup).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a patroniBackup and updates it. Returns the server's representation of the patroniBackup, and an error, if there is any.
+func (c *patroniBackups) Update(ctx context.Context, patroniBackup *v1alpha1.PatroniBackup, opts v1.UpdateOptions) (result *v1alpha1.PatroniBackup, err error) {
+	result = &v1alpha1.PatroniBackup{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		Name(patroniBackup.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(patroniBackup).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the patroniBackup and deletes it. Returns an error if one occurs.
+func (c *patroniBackups) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *patroniBackups) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("patronibackups").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched patroniBackup.
+func (c *patroniBackups) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.PatroniBackup, err error) {
+	result = &v1alpha1.PatroniBackup{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("patronibackups").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -24,6 +24,8 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// PatroniBackups returns a PatroniBackupInformer.
+	PatroniBackups() PatroniBackupInformer
 	// PatroniClusters returns a PatroniClusterInformer.
 	PatroniClusters() PatroniClusterInformer
 }
@@ -39,6 +41,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// PatroniBackups returns a PatroniBackupInformer.
+func (v *version) PatroniBackups() PatroniBackupInformer {
+	return &patroniBackupInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // PatroniClusters returns a PatroniClusterInformer.
 func (v *version) PatroniClusters() PatroniClusterInformer {
 	return &patroniClusterInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
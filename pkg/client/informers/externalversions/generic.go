@@ -53,6 +53,8 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=rccp.ruijie.com.cn, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("patronibackups"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Rccp().V1alpha1().PatroniBackups().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("patroniclusters"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Rccp().V1alpha1().PatroniClusters().Informer()}, nil
 
@@ -18,6 +18,14 @@ limitations under the License.
 
 package v1alpha1
 
+// PatroniBackupListerExpansion allows custom methods to be added to
+// PatroniBackupLister.
+type PatroniBackupListerExpansion interface{}
+
+// PatroniBackupNamespaceListerExpansion allows custom methods to be added to
+// PatroniBackupNamespaceLister.
+type PatroniBackupNamespaceListerExpansion interface{}
+
 // PatroniClusterListerExpansion allows custom methods to be added to
 // PatroniClusterLister.
 type PatroniClusterListerExpansion interface{}
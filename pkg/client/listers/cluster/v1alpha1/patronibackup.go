@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The RUIJIE Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PatroniBackupLister helps list PatroniBackups.
+// All objects returned here must be treated as read-only.
+type PatroniBackupLister interface {
+	// List lists all PatroniBackups in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.PatroniBackup, err error)
+	// PatroniBackups returns an object that can list and get PatroniBackups.
+	PatroniBackups(namespace string) PatroniBackupNamespaceLister
+	PatroniBackupListerExpansion
+}
+
+// patroniBackupLister implements the PatroniBackupLister interface.
+type patroniBackupLister struct {
+	indexer cache.Indexer
+}
+
+// NewPatroniBackupLister returns a new PatroniBackupLister.
+func NewPatroniBackupLister(indexer cache.Indexer) PatroniBackupLister {
+	return &patroniBackupLister{indexer: indexer}
+}
+
+// List lists all PatroniBackups in the indexer.
+func (s *patroniBackupLister) List(selector labels.Selector) (ret []*v1alpha1.PatroniBackup, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.PatroniBackup))
+	})
+	return ret, err
+}
+
+// PatroniBackups returns an object that can list and get PatroniBackups.
+func (s *patroniBackupLister) PatroniBackups(namespace string) PatroniBackupNamespaceLister {
+	return patroniBackupNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// PatroniBackupNamespaceLister helps list and get PatroniBackups.
+// All objects returned here must be treated as read-only.
+type PatroniBackupNamespaceLister interface {
+	// List lists all PatroniBackups in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.PatroniBackup, err error)
+	// Get retrieves the PatroniBackup from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.PatroniBackup, error)
+	PatroniBackupNamespaceListerExpansion
+}
+
+// patroniBackupNamespaceLister implements the PatroniBackupNamespaceLister
+// interface.
+type patroniBackupNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all PatroniBackups in the indexer for a given namespace.
+func (s patroniBackupNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.PatroniBackup, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.PatroniBackup))
+	})
+	return ret, err
+}
+
+// Get retrieves the PatroniBackup from the indexer for a given namespace and name.
+func (s patroniBackupNamespaceLister) Get(name string) (*v1alpha1.PatroniBackup, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("patronibackup"), name)
+	}
+	return obj.(*v1alpha1.PatroniBackup), nil
+}
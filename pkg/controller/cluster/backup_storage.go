@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	coreV1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"pgoperator/pkg/apis/cluster/v1alpha1"
+	"strconv"
+	"strings"
+)
+
+// 事件类型
+const (
+	reasonInvalidBackupStorage = "InvalidBackupStorage"
+)
+
+const (
+	backupAccessKeyIdKey     = "accessKeyId"
+	backupSecretAccessKeyKey = "secretAccessKey"
+	defaultArchiveTimeout    = 60
+	walPushCommand           = "wal-g wal-push %p"
+	walFetchCommand          = "wal-g wal-fetch %f %p"
+)
+
+// backupPrefix 集群备份在对象存储中的位置
+func backupPrefix(pCluster *v1alpha1.PatroniCluster) string {
+	return s3Prefix(pCluster.PatroniClusterSpec.BackupStorage.S3, fmt.Sprintf("%s/%s", pCluster.Namespace, pCluster.Name))
+}
+
+func s3Prefix(s3 v1alpha1.S3Storage, defaultPath string) string {
+	path := strings.Trim(s3.Path, "/")
+	if path == "" {
+		path = defaultPath
+	}
+	return fmt.Sprintf("s3://%s/%s", s3.Bucket, path)
+}
+
+// backupEnv wal-g 访问对象存储和本地数据库使用的环境变量，archive_command 继承 Patroni 进程的环境变量
+func backupEnv(pCluster *v1alpha1.PatroniCluster) []coreV1.EnvVar {
+
+	storage := pCluster.PatroniClusterSpec.BackupStorage
+	if storage == nil {
+		return nil
+	}
+
+	env := s3Env("", backupPrefix(pCluster), storage.S3)
+	return append(env,
+		coreV1.EnvVar{Name: "PGDATA", Value: defaultPgDataPath},
+		coreV1.EnvVar{Name: "PGHOST", Value: "localhost"},
+		coreV1.EnvVar{Name: "PGPORT", Value: "5432"},
+		coreV1.EnvVar{Name: "PGUSER", Value: superUserName(pCluster)},
+		secretEnv("PGPASSWORD", pCluster.PatroniClusterSpec.SuperUserSecretName, secretPasswordKey),
+	)
+}
+
+// s3Env wal-g 访问对象存储的环境变量，namePrefix 用于区分多个对象存储
+func s3Env(namePrefix, prefix string, s3 v1alpha1.S3Storage) []coreV1.EnvVar {
+
+	env := []coreV1.EnvVar{
+		{Name: namePrefix + "WALG_S3_PREFIX", Value: prefix},
+		{Name: namePrefix + "AWS_REGION", Value: s3.Region},
+		secretEnv(namePrefix+"AWS_ACCESS_KEY_ID", s3.CredentialsSecret, backupAccessKeyIdKey),
+		secretEnv(namePrefix+"AWS_SECRET_ACCESS_KEY", s3.CredentialsSecret, backupSecretAccessKeyKey),
+	}
+	if s3.Endpoint != "" {
+		env = append(env, coreV1.EnvVar{Name: namePrefix + "AWS_ENDPOINT", Value: s3.Endpoint})
+	}
+	if s3.ForcePathStyle {
+		env = append(env, coreV1.EnvVar{Name: namePrefix + "AWS_S3_FORCE_PATH_STYLE", Value: "true"})
+	}
+
+	return env
+}
+
+func secretEnv(name, secret, key string) coreV1.EnvVar {
+	return coreV1.EnvVar{
+		Name: name,
+		ValueFrom: &coreV1.EnvVarSource{
+			SecretKeyRef: &coreV1.SecretKeySelector{
+				LocalObjectReference: coreV1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// archiveParameters 开启 WAL 归档的参数，archive_mode 需要重启才能生效
+func archiveParameters(pCluster *v1alpha1.PatroniCluster) map[string]string {
+
+	storage := pCluster.PatroniClusterSpec.BackupStorage
+	if storage == nil {
+		return nil
+	}
+
+	timeout := int32(defaultArchiveTimeout)
+	if storage.ArchiveTimeout != nil {
+		timeout = *storage.ArchiveTimeout
+	}
+
+	return map[string]string{
+		"archive_mode":    "on",
+		"archive_command": walPushCommand,
+		"archive_timeout": strconv.Itoa(int(timeout)),
+	}
+}
+
+// checkBackupStorage 检查备份存储引用的 Secret 是否包含访问密钥
+func (c *patroniClusterController) checkBackupStorage(pCluster *v1alpha1.PatroniCluster) error {
+
+	storage := pCluster.PatroniClusterSpec.BackupStorage
+	if storage == nil {
+		return nil
+	}
+
+	return c.checkS3Credentials(pCluster.Namespace, storage.S3.CredentialsSecret, "spec.backupStorage.s3.credentialsSecret")
+}
+
+func (c *patroniClusterController) checkS3Credentials(ns, name, field string) error {
+
+	secret, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return fmt.Errorf("secret %s/%s referenced by %s not found", ns, name, field)
+		}
+		return errors.Wrapf(err, "get secret %s/%s failed", ns, name)
+	}
+
+	for _, key := range []string{backupAccessKeyIdKey, backupSecretAccessKeyKey} {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("secret %s/%s has no %q key", ns, name, key)
+		}
+	}
+
+	return nil
+}
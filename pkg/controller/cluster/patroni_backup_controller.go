@@ -0,0 +1,591 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"os"
+	"pgoperator/cmd/controller/app/options"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	pgOperatorCli "pgoperator/pkg/client/clientset/versioned"
+	"pgoperator/pkg/client/clientset/versioned/scheme"
+	clusterInformer "pgoperator/pkg/client/informers/externalversions/cluster/v1alpha1"
+	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
+	"pgoperator/pkg/constants"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"time"
+)
+
+// 事件类型
+const (
+	reasonBackupStarted   = "BackupStarted"
+	reasonBackupCompleted = "BackupCompleted"
+	reasonBackupFailed    = "BackupFailed"
+	reasonBackupDeleted   = "BackupDeleted"
+	reasonBackupRetained  = "BackupRetained"
+	reasonDeleteFailed    = "BackupDeleteFailed"
+)
+
+const (
+	patroniBackupFinalizerStr = "patroni-backup-controller"
+	// 备份进程的输出和退出码保存在成员容器的临时目录中
+	backupFilePrefix   = "/tmp/patroni-backup-"
+	backupPollInterval = 10 * time.Second
+	// 默认的 WAL 段文件大小
+	walSegmentSize = 16 * 1024 * 1024
+	// 增量备份链的最大长度，超过后自动创建完整备份
+	walgDeltaMaxSteps = 6
+)
+
+type patroniBackupController struct {
+	eventBroadcaster record.EventBroadcaster
+	eventRecorder    record.EventRecorder
+
+	kubernetesCli kubernetes.Interface
+	restConfig    *rest.Config
+	execInPod     podExecFunc
+
+	pgOperatorCli pgOperatorCli.Interface
+	statusWriter  statusWriter
+	backupLister  clusterLister.PatroniBackupLister
+	backupSynced  cache.InformerSynced
+	backupQueue   workqueue.RateLimitingInterface
+	clusterLister clusterLister.PatroniClusterLister
+	clusterSynced cache.InformerSynced
+
+	workerCount int
+	retryCount  int
+	period      time.Duration
+	waitPeriod  time.Duration
+
+	mrgConfig *options.Config
+}
+
+func NewPatroniBackupController(kubernetesCli kubernetes.Interface, restConfig *rest.Config, pgOperatorCli pgOperatorCli.Interface,
+	backupInformer clusterInformer.PatroniBackupInformer, clusterInformer clusterInformer.PatroniClusterInformer,
+	mgrConfig *options.Config) *patroniBackupController {
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(func(format string, args ...interface{}) {
+		klog.Info(fmt.Sprintf(format, args))
+	})
+
+	controllerNamespace := os.Getenv(constants.ControllerNamespaceEnvironment)
+
+	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kubernetesCli.CoreV1().Events(controllerNamespace)})
+	r := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "patroni-backup-controller"})
+
+	c := &patroniBackupController{
+		eventBroadcaster: broadcaster,
+		eventRecorder:    r,
+		kubernetesCli:    kubernetesCli,
+		restConfig:       restConfig,
+		execInPod:        newPodExecFunc(kubernetesCli, restConfig),
+		pgOperatorCli:    pgOperatorCli,
+		statusWriter:     newStatusWriter(pgOperatorCli),
+		backupLister:     backupInformer.Lister(),
+		backupSynced:     backupInformer.Informer().HasSynced,
+		backupQueue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "patroni-backup"),
+		clusterLister:    clusterInformer.Lister(),
+		clusterSynced:    clusterInformer.Informer().HasSynced,
+		workerCount:      2,
+		retryCount:       3,
+		period:           1 * time.Second,
+		waitPeriod:       backupPollInterval,
+		mrgConfig:        mgrConfig,
+	}
+
+	backupInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueBackup(newObj)
+		},
+		AddFunc:    c.enqueueBackup,
+		DeleteFunc: c.enqueueBackup,
+	})
+
+	return c
+}
+
+func (c *patroniBackupController) enqueueBackup(obj interface{}) {
+
+	backupObj, ok := obj.(*clusterv1alpha1.PatroniBackup)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if backupObj, ok = tombstone.Obj.(*clusterv1alpha1.PatroniBackup); !ok {
+			return
+		}
+	}
+	key, err := cache.MetaNamespaceKeyFunc(backupObj)
+	if err != nil {
+		utilruntime.HandleError(errors.Errorf("get patroni backup key %s failed", backupObj.Name))
+		return
+	}
+	c.backupQueue.Add(key)
+}
+
+func (c *patroniBackupController) backupWork() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *patroniBackupController) processNextItem() bool {
+	key, quit := c.backupQueue.Get()
+
+	if quit {
+		return false
+	}
+
+	defer c.backupQueue.Done(key)
+
+	result, err := c.handleBackup(key.(string))
+
+	if err != nil {
+		if c.backupQueue.NumRequeues(key) < c.retryCount {
+			klog.Errorf("Error syncing PatroniBackup %s, retrying, %v", key, err)
+			c.backupQueue.AddRateLimited(key)
+		} else {
+			c.backupQueue.Forget(key)
+			utilruntime.HandleError(err)
+		}
+		return true
+	}
+
+	if result.RequeueAfter > 0 {
+		c.backupQueue.Forget(key)
+		c.backupQueue.AddAfter(key, result.RequeueAfter)
+		return true
+	} else if result.Requeue {
+		c.backupQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.backupQueue.Forget(key)
+	return true
+}
+
+func (c *patroniBackupController) Run(ctx context.Context) error {
+	defer func() {
+		utilruntime.HandleCrash()
+		c.backupQueue.ShutDown()
+		klog.V(2).Infof("shutting down patroni backup controller")
+	}()
+
+	klog.V(0).Infof("starting patroni backup controller")
+	if !cache.WaitForCacheSync(ctx.Done(), c.backupSynced, c.clusterSynced) {
+		return errors.New("failed to wait for cached to sync")
+	}
+
+	for i := 0; i < c.workerCount; i++ {
+		go wait.Until(c.backupWork, c.period, ctx.Done())
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (c *patroniBackupController) Start(ctx context.Context) error {
+	return c.Run(ctx)
+}
+
+// handleBackup 备份在成员容器中后台执行，控制器周期性检查备份进程的退出码，
+// 完成后从对象存储中读取备份详情。删除备份对象时同时从对象存储中删除备份数据
+func (c *patroniBackupController) handleBackup(key string) (ctrl.Result, error) {
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "not a valid controller key %s", key))
+		return ctrl.Result{}, err
+	}
+
+	backup, err := c.backupLister.PatroniBackups(ns).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		klog.Error(errors.Wrapf(err, "Failed to get patroni-backup object on cache %s/%s", ns, name))
+		return ctrl.Result{}, err
+	}
+
+	backup = backup.DeepCopy()
+
+	if !backup.ObjectMeta.DeletionTimestamp.IsZero() {
+		return c.deleteBackup(backup)
+	}
+
+	if !sets.NewString(backup.ObjectMeta.Finalizers...).Has(patroniBackupFinalizerStr) {
+		backup.ObjectMeta.Finalizers = append(backup.ObjectMeta.Finalizers, patroniBackupFinalizerStr)
+		backup, err = c.pgOperatorCli.RccpV1alpha1().PatroniBackups(ns).Update(context.Background(), backup, metav1.UpdateOptions{})
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "add finalizer to patroni backup %s/%s failed", ns, name)
+		}
+	}
+
+	switch backup.PatroniBackupStatus.Phase {
+	case "", clusterv1alpha1.BackupPending:
+		return c.startBackup(backup)
+	case clusterv1alpha1.BackupRunning:
+		return c.checkBackup(backup)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (c *patroniBackupController) startBackup(backup *clusterv1alpha1.PatroniBackup) (ctrl.Result, error) {
+
+	ns := backup.Namespace
+	pCluster, err := c.clusterLister.PatroniClusters(ns).Get(backup.PatroniBackupSpec.ClusterName)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return c.failBackup(backup, fmt.Sprintf("patroni cluster %s not found", backup.PatroniBackupSpec.ClusterName))
+		}
+		return ctrl.Result{}, err
+	}
+	if pCluster.PatroniClusterSpec.BackupStorage == nil {
+		return c.failBackup(backup, fmt.Sprintf("patroni cluster %s has no backup storage", pCluster.Name))
+	}
+
+	pod, err := backupMember(c.kubernetesCli, pCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pod == nil {
+		backup.PatroniBackupStatus.Phase = clusterv1alpha1.BackupPending
+		backup.PatroniBackupStatus.Message = "waiting for a ready member"
+		if err := c.updateBackupStatus(backup); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+	}
+
+	// 先保存 Running 状态再启动备份进程，状态写回冲突时重新调谐不会启动第二个备份
+	now := metav1.Now()
+	backup.PatroniBackupStatus.Phase = clusterv1alpha1.BackupRunning
+	backup.PatroniBackupStatus.Member = pod.Name
+	backup.PatroniBackupStatus.StartTime = &now
+	backup.PatroniBackupStatus.Message = ""
+	if err := c.updateBackupStatus(backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 启动失败时由 checkBackup 重新启动
+	if err := c.launchBackup(backup, pod); err != nil {
+		c.eventRecorder.Eventf(backup, v1.EventTypeWarning, reasonBackupFailed, "start backup on %s failed: %v", pod.Name, err)
+		return ctrl.Result{RequeueAfter: backupPollInterval}, nil
+	}
+
+	c.eventRecorder.Eventf(backup, v1.EventTypeNormal, reasonBackupStarted, "start base backup on %s", pod.Name)
+	return ctrl.Result{RequeueAfter: backupPollInterval}, nil
+}
+
+// launchBackup 在成员容器中后台执行 wal-g backup-push，输出文件已经存在时说明备份进程已经启动，脚本直接退出。
+// 通过 user_data 记录备份对象名称，完成后据此在对象存储中找到对应的备份；
+// 增量备份通过 WALG_DELTA_MAX_STEPS 开启，完整备份使用 --full 忽略已有的基础备份
+func (c *patroniBackupController) launchBackup(backup *clusterv1alpha1.PatroniBackup, pod *v1.Pod) error {
+
+	file := backupFilePrefix + backup.Name
+	pushArgs := "--full"
+	if backup.PatroniBackupSpec.Type == clusterv1alpha1.BackupIncremental {
+		pushArgs = ""
+	}
+	script := fmt.Sprintf(`if [ -f %[1]s.exit ] || [ -f %[1]s.log ]; then exit 0; fi
+export BACKUP_USER_DATA='{"name":"%[2]s"}'
+export WALG_DELTA_MAX_STEPS=%[4]d
+nohup sh -c 'wal-g backup-push "$PGDATA" %[3]s --add-user-data "$BACKUP_USER_DATA"; echo $? > %[1]s.exit' > %[1]s.log 2>&1 < /dev/null &
+`, file, backup.Name, pushArgs, walgDeltaMaxSteps)
+	_, err := c.execInPod(pod, []string{"sh", "-s"}, strings.NewReader(script))
+	return err
+}
+
+// backupMember 选择执行备份的成员，优先使用就绪的 replica 以减少 leader 的负载
+func backupMember(kubernetesCli kubernetes.Interface, pCluster *clusterv1alpha1.PatroniCluster) (*v1.Pod, error) {
+
+	selector := labels.SelectorFromSet(map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}).String()
+	pods, err := kubernetesCli.CoreV1().Pods(pCluster.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var leader *v1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) {
+			continue
+		}
+		if pod.Labels[patroniRoleLabel] == patroniReplicaRole {
+			return pod, nil
+		}
+		if patroniLeaderRoles.Has(pod.Labels[patroniRoleLabel]) {
+			leader = pod
+		}
+	}
+
+	return leader, nil
+}
+
+func (c *patroniBackupController) checkBackup(backup *clusterv1alpha1.PatroniBackup) (ctrl.Result, error) {
+
+	ns := backup.Namespace
+	pod, err := c.kubernetesCli.CoreV1().Pods(ns).Get(context.Background(), backup.PatroniBackupStatus.Member, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return c.failBackup(backup, fmt.Sprintf("member %s deleted during backup", backup.PatroniBackupStatus.Member))
+		}
+		return ctrl.Result{}, err
+	}
+
+	file := backupFilePrefix + backup.Name
+	script := fmt.Sprintf("if [ -f %[1]s.exit ]; then cat %[1]s.exit; elif [ -f %[1]s.log ]; then echo running; else echo lost; fi", file)
+	out, err := c.execInPod(pod, []string{"sh", "-c", script}, nil)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	code := strings.TrimSpace(out)
+	switch code {
+	case "running":
+		return ctrl.Result{RequeueAfter: backupPollInterval}, nil
+	case "lost":
+		if memberRestarted(pod, backup.PatroniBackupStatus.StartTime) {
+			return c.failBackup(backup, fmt.Sprintf("backup process on %s lost, member restarted", pod.Name))
+		}
+	case "0":
+	default:
+		logs, _ := c.execInPod(pod, []string{"tail", "-n", "5", file + ".log"}, nil)
+		result, err := c.failBackup(backup, fmt.Sprintf("wal-g backup-push exited with %s: %s", code, strings.TrimSpace(logs)))
+		if err == nil {
+			c.cleanupBackupFiles(pod, file)
+		}
+		return result, err
+	}
+
+	b, err := c.findBackup(pod, backup.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if b == nil {
+		if code == "lost" {
+			// Running 状态已经保存但备份进程没有启动
+			if err := c.launchBackup(backup, pod); err != nil {
+				return ctrl.Result{}, err
+			}
+			c.eventRecorder.Eventf(backup, v1.EventTypeNormal, reasonBackupStarted, "start base backup on %s", pod.Name)
+			return ctrl.Result{RequeueAfter: backupPollInterval}, nil
+		}
+		return c.failBackup(backup, "backup finished but not found in backup storage")
+	}
+
+	status := &backup.PatroniBackupStatus
+	now := metav1.Now()
+	status.Phase = clusterv1alpha1.BackupCompleted
+	status.CompletionTime = &now
+	status.BackupName = b.BackupName
+	status.SizeBytes = b.CompressedSize
+	status.Size = formatBytes(b.CompressedSize)
+	status.StartLSN = formatLSN(b.StartLsn)
+	status.EndLSN = formatLSN(b.FinishLsn)
+	status.BeginWAL = b.WalFileName
+	status.EndWAL = walFileName(b.WalFileName, b.FinishLsn)
+	status.Message = ""
+	if err := c.updateBackupStatus(backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 状态保存之后再清理输出文件，写回失败时下次检查仍能找到备份
+	c.cleanupBackupFiles(pod, file)
+	c.eventRecorder.Eventf(backup, v1.EventTypeNormal, reasonBackupCompleted, "base backup %s completed, size %s", b.BackupName, status.Size)
+	return ctrl.Result{}, nil
+}
+
+// memberRestarted 容器在备份开始之后重启，临时目录中的备份进程和输出文件已经丢失
+func memberRestarted(pod *v1.Pod, since *metav1.Time) bool {
+	if since == nil {
+		return false
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.Name != postgresContainerName {
+			continue
+		}
+		return s.State.Running == nil || since.Before(&s.State.Running.StartedAt)
+	}
+	return false
+}
+
+// findBackup 根据 user_data 中记录的备份对象名称在对象存储中查找备份
+func (c *patroniBackupController) findBackup(pod *v1.Pod, name string) (*walgBackup, error) {
+
+	out, err := c.execInPod(pod, []string{"wal-g", "backup-list", "--detail", "--json"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 对象存储中没有备份时没有输出
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+
+	var backups []walgBackup
+	if err := json.Unmarshal([]byte(out), &backups); err != nil {
+		return nil, errors.Wrap(err, "parse wal-g backup list failed")
+	}
+	for i := range backups {
+		if backups[i].UserData.Name == name {
+			return &backups[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// deleteBackup 等待正在执行的备份结束，从对象存储删除备份数据后移除 Finalizer
+func (c *patroniBackupController) deleteBackup(backup *clusterv1alpha1.PatroniBackup) (ctrl.Result, error) {
+
+	finalizers := sets.NewString(backup.ObjectMeta.Finalizers...)
+	if !finalizers.Has(patroniBackupFinalizerStr) {
+		return ctrl.Result{}, nil
+	}
+
+	status := backup.PatroniBackupStatus
+	if status.Phase == clusterv1alpha1.BackupRunning {
+		return c.checkBackup(backup)
+	}
+
+	if status.Phase == clusterv1alpha1.BackupCompleted && status.BackupName != "" {
+		done, err := c.deleteBackupData(backup)
+		if err != nil {
+			c.eventRecorder.Eventf(backup, v1.EventTypeWarning, reasonDeleteFailed, "delete backup %s from backup storage failed: %v", status.BackupName, err)
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+		}
+	}
+
+	finalizers.Delete(patroniBackupFinalizerStr)
+	backup.ObjectMeta.Finalizers = finalizers.List()
+	if _, err := c.pgOperatorCli.RccpV1alpha1().PatroniBackups(backup.Namespace).Update(context.Background(), backup, metav1.UpdateOptions{}); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "remove finalizer from patroni backup %s/%s failed", backup.Namespace, backup.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteBackupData 通过集群成员执行 wal-g delete target，依赖该备份的增量备份一起删除。
+// 集群已经删除或不再配置备份存储时保留对象存储中的数据
+func (c *patroniBackupController) deleteBackupData(backup *clusterv1alpha1.PatroniBackup) (bool, error) {
+
+	backupName := backup.PatroniBackupStatus.BackupName
+	pCluster, err := c.clusterLister.PatroniClusters(backup.Namespace).Get(backup.PatroniBackupSpec.ClusterName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	if pCluster == nil || !pCluster.ObjectMeta.DeletionTimestamp.IsZero() || pCluster.PatroniClusterSpec.BackupStorage == nil {
+		c.eventRecorder.Eventf(backup, v1.EventTypeNormal, reasonBackupRetained, "patroni cluster %s is not available, backup %s retained in backup storage",
+			backup.PatroniBackupSpec.ClusterName, backupName)
+		return true, nil
+	}
+
+	pod, err := backupMember(c.kubernetesCli, pCluster)
+	if err != nil {
+		return false, err
+	}
+	if pod == nil {
+		klog.V(4).Infof("delete backup %s/%s waiting for a ready member", backup.Namespace, backup.Name)
+		return false, nil
+	}
+
+	// 完整备份被删除时依赖它的增量备份已经一起删除
+	b, err := c.findBackup(pod, backup.Name)
+	if err != nil || b == nil {
+		return err == nil, err
+	}
+	if _, err := c.execInPod(pod, []string{"wal-g", "delete", "target", b.BackupName, "--confirm"}, nil); err != nil {
+		return false, err
+	}
+
+	c.eventRecorder.Eventf(backup, v1.EventTypeNormal, reasonBackupDeleted, "delete backup %s from backup storage", b.BackupName)
+	return true, nil
+}
+
+func (c *patroniBackupController) cleanupBackupFiles(pod *v1.Pod, file string) {
+	if _, err := c.execInPod(pod, []string{"rm", "-f", file + ".log", file + ".exit"}, nil); err != nil {
+		klog.V(4).Infof("cleanup backup files on %s/%s failed: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
+func (c *patroniBackupController) failBackup(backup *clusterv1alpha1.PatroniBackup, message string) (ctrl.Result, error) {
+
+	now := metav1.Now()
+	backup.PatroniBackupStatus.Phase = clusterv1alpha1.BackupFailed
+	backup.PatroniBackupStatus.CompletionTime = &now
+	backup.PatroniBackupStatus.Message = message
+	if err := c.updateBackupStatus(backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	c.eventRecorder.Event(backup, v1.EventTypeWarning, reasonBackupFailed, message)
+	return ctrl.Result{}, nil
+}
+
+// updateBackupStatus 通过 status 子资源更新备份状态
+func (c *patroniBackupController) updateBackupStatus(backup *clusterv1alpha1.PatroniBackup) error {
+	_, err := c.statusWriter.UpdateBackupStatus(backup)
+	return err
+}
+
+// walgBackup wal-g backup-list --detail --json 返回的备份详情
+type walgBackup struct {
+	BackupName     string `json:"backup_name"`
+	WalFileName    string `json:"wal_file_name"`
+	StartLsn       uint64 `json:"start_lsn"`
+	FinishLsn      uint64 `json:"finish_lsn"`
+	CompressedSize int64  `json:"compressed_size"`
+	UserData       struct {
+		Name string `json:"name"`
+	} `json:"user_data"`
+}
+
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", lsn>>32, lsn&0xFFFFFFFF)
+}
+
+// walFileName 根据起始 WAL 文件的时间线计算 lsn 所在的 WAL 文件
+func walFileName(beginWAL string, lsn uint64) string {
+	if len(beginWAL) < 8 {
+		return ""
+	}
+	return fmt.Sprintf("%s%08X%08X", beginWAL[:8], lsn>>32, (lsn&0xFFFFFFFF)/walSegmentSize)
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,552 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	pgOperatorFake "pgoperator/pkg/client/clientset/versioned/fake"
+	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakePgOperatorCli 返回 PatroniCluster 和 PatroniBackup 的 fake clientset，
+// 通过 reactor 补充 tracker 缺少的 resourceVersion 冲突检查、status 子资源和 Finalizer 删除语义
+func newFakePgOperatorCli() *pgOperatorFake.Clientset {
+
+	client := pgOperatorFake.NewSimpleClientset()
+	tracker := client.Tracker()
+	version := 0
+	bump := func(obj metav1.Object) {
+		version++
+		obj.SetResourceVersion(strconv.Itoa(version))
+	}
+
+	client.PrependReactor("create", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().DeepCopyObject()
+		accessor, _ := meta.Accessor(obj)
+		if creation := accessor.GetCreationTimestamp(); creation.IsZero() {
+			accessor.SetCreationTimestamp(metav1.Now())
+		}
+		bump(accessor)
+		// 创建时忽略 status
+		setObjectStatus(obj, nil)
+		if err := tracker.Create(action.GetResource(), obj, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	client.PrependReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.UpdateAction).GetObject().DeepCopyObject()
+		accessor, _ := meta.Accessor(obj)
+		gvr, ns := action.GetResource(), action.GetNamespace()
+		current, err := tracker.Get(gvr, ns, accessor.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		currentAccessor, _ := meta.Accessor(current)
+		if accessor.GetResourceVersion() != currentAccessor.GetResourceVersion() {
+			return true, nil, k8serrors.NewConflict(gvr.GroupResource(), accessor.GetName(), fmt.Errorf("the object has been modified"))
+		}
+		if action.GetSubresource() == "status" {
+			// status 子资源只更新状态
+			setObjectStatus(current, obj)
+			obj, accessor = current, currentAccessor
+		} else {
+			setObjectStatus(obj, current)
+			accessor.SetDeletionTimestamp(currentAccessor.GetDeletionTimestamp())
+		}
+		bump(accessor)
+		// 删除中的对象没有 Finalizer 时真正删除
+		if accessor.GetDeletionTimestamp() != nil && len(accessor.GetFinalizers()) == 0 {
+			return true, obj, tracker.Delete(gvr, ns, accessor.GetName())
+		}
+		return true, obj, tracker.Update(gvr, obj, ns)
+	})
+
+	client.PrependReactor("delete", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gvr, ns, name := action.GetResource(), action.GetNamespace(), action.(k8stesting.DeleteAction).GetName()
+		current, err := tracker.Get(gvr, ns, name)
+		if err != nil {
+			return true, nil, err
+		}
+		accessor, _ := meta.Accessor(current)
+		if len(accessor.GetFinalizers()) == 0 {
+			return true, nil, tracker.Delete(gvr, ns, name)
+		}
+		if accessor.GetDeletionTimestamp() == nil {
+			now := metav1.Now()
+			accessor.SetDeletionTimestamp(&now)
+			bump(accessor)
+		}
+		return true, nil, tracker.Update(gvr, current, ns)
+	})
+
+	return client
+}
+
+// setObjectStatus 将 from 的状态复制到 obj，from 为空时清空状态
+func setObjectStatus(obj, from runtime.Object) {
+	switch o := obj.(type) {
+	case *clusterv1alpha1.PatroniCluster:
+		o.PatroniClusterStatus = clusterv1alpha1.PatroniClusterStatus{}
+		if f, ok := from.(*clusterv1alpha1.PatroniCluster); ok {
+			o.PatroniClusterStatus = f.PatroniClusterStatus
+		}
+	case *clusterv1alpha1.PatroniBackup:
+		o.PatroniBackupStatus = clusterv1alpha1.PatroniBackupStatus{}
+		if f, ok := from.(*clusterv1alpha1.PatroniBackup); ok {
+			o.PatroniBackupStatus = f.PatroniBackupStatus
+		}
+	}
+}
+
+// fakeStatusWriter 通过 fake clientset 的 status 子资源更新状态
+type fakeStatusWriter struct {
+	client *pgOperatorFake.Clientset
+}
+
+func (w *fakeStatusWriter) UpdateClusterStatus(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+	gvr := clusterv1alpha1.SchemeGroupVersion.WithResource("patroniclusters")
+	obj, err := w.client.Invokes(k8stesting.NewUpdateSubresourceAction(gvr, "status", pCluster.Namespace, pCluster), &clusterv1alpha1.PatroniCluster{})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*clusterv1alpha1.PatroniCluster), nil
+}
+
+func (w *fakeStatusWriter) UpdateBackupStatus(backup *clusterv1alpha1.PatroniBackup) (*clusterv1alpha1.PatroniBackup, error) {
+	gvr := clusterv1alpha1.SchemeGroupVersion.WithResource("patronibackups")
+	obj, err := w.client.Invokes(k8stesting.NewUpdateSubresourceAction(gvr, "status", backup.Namespace, backup), &clusterv1alpha1.PatroniBackup{})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*clusterv1alpha1.PatroniBackup), nil
+}
+
+// fakeWalg 成员容器中的 wal-g 和对象存储替身，backups 为对象存储中的备份
+type fakeWalg struct {
+	files   map[string]string
+	backups []walgBackup
+	// 启动的 backup-push 对应的备份对象名称
+	pushes []string
+	// wal-g delete target 删除的备份
+	deletes []string
+	// 下一次启动备份失败
+	failLaunch bool
+}
+
+var (
+	fakeBackupFile = regexp.MustCompile(`-f (\S+)\.exit`)
+	fakeUserData   = regexp.MustCompile(`"name":"([^"]+)"`)
+)
+
+func (f *fakeWalg) exec(pod *v1.Pod, command []string, stdin io.Reader) (string, error) {
+
+	switch {
+	case len(command) == 2 && command[0] == "sh" && command[1] == "-s":
+		data, _ := ioutil.ReadAll(stdin)
+		script := string(data)
+		if f.failLaunch {
+			f.failLaunch = false
+			return "", fmt.Errorf("exec in pod %s failed", pod.Name)
+		}
+		file := fakeBackupFile.FindStringSubmatch(script)[1]
+		if _, ok := f.files[file+".exit"]; ok {
+			return "", nil
+		}
+		if _, ok := f.files[file+".log"]; ok {
+			return "", nil
+		}
+		f.files[file+".log"] = ""
+		f.pushes = append(f.pushes, fakeUserData.FindStringSubmatch(script)[1])
+		return "", nil
+	case len(command) == 3 && command[0] == "sh" && command[1] == "-c":
+		file := fakeBackupFile.FindStringSubmatch(command[2])[1]
+		if code, ok := f.files[file+".exit"]; ok {
+			return code + "\n", nil
+		}
+		if _, ok := f.files[file+".log"]; ok {
+			return "running\n", nil
+		}
+		return "lost\n", nil
+	case strings.Join(command, " ") == "wal-g backup-list --detail --json":
+		if len(f.backups) == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(f.backups)
+		return string(data), err
+	case len(command) == 5 && strings.Join(command[:3], " ") == "wal-g delete target" && command[4] == "--confirm":
+		for i, b := range f.backups {
+			if b.BackupName == command[3] {
+				f.backups = append(f.backups[:i], f.backups[i+1:]...)
+				f.deletes = append(f.deletes, command[3])
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("backup %s not found", command[3])
+	case command[0] == "rm":
+		for _, file := range command[2:] {
+			delete(f.files, file)
+		}
+		return "", nil
+	case command[0] == "tail":
+		return f.files[command[len(command)-1]], nil
+	}
+
+	return "", fmt.Errorf("unexpected command %v", command)
+}
+
+// finish 备份进程以 code 退出，成功时备份写入对象存储
+func (f *fakeWalg) finish(name string, code int, backupName string) {
+	file := backupFilePrefix + name
+	f.files[file+".exit"] = strconv.Itoa(code)
+	if code != 0 {
+		f.files[file+".log"] = "ERROR: connect to postgres failed"
+		return
+	}
+	b := walgBackup{
+		BackupName:     backupName,
+		WalFileName:    "000000010000000000000002",
+		StartLsn:       0x2000028,
+		FinishLsn:      0x2000100,
+		CompressedSize: 4 * 1024 * 1024,
+	}
+	b.UserData.Name = name
+	f.backups = append(f.backups, b)
+}
+
+type backupTestEnv struct {
+	t        *testing.T
+	client   *pgOperatorFake.Clientset
+	walg     *fakeWalg
+	backups  cache.Indexer
+	clusters cache.Indexer
+	c        *patroniBackupController
+}
+
+func newBackupTestEnv(t *testing.T, pods ...*v1.Pod) *backupTestEnv {
+
+	client := newFakePgOperatorCli()
+	kubernetesCli := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		kubernetesCli.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	}
+
+	env := &backupTestEnv{
+		t:        t,
+		client:   client,
+		walg:     &fakeWalg{files: map[string]string{}},
+		backups:  cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+		clusters: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}),
+	}
+	env.c = &patroniBackupController{
+		eventRecorder: record.NewFakeRecorder(100),
+		kubernetesCli: kubernetesCli,
+		execInPod:     env.walg.exec,
+		pgOperatorCli: client,
+		statusWriter:  &fakeStatusWriter{client: client},
+		backupLister:  clusterLister.NewPatroniBackupLister(env.backups),
+		clusterLister: clusterLister.NewPatroniClusterLister(env.clusters),
+		waitPeriod:    backupPollInterval,
+	}
+	return env
+}
+
+// refresh 模拟 informer 将 clientset 中的对象同步到 lister 缓存
+func (e *backupTestEnv) refresh() {
+
+	var backups, clusters []interface{}
+	backupList, _ := e.client.RccpV1alpha1().PatroniBackups("").List(context.Background(), metav1.ListOptions{})
+	for _, obj := range backupList.Items {
+		backups = append(backups, obj)
+	}
+	clusterList, _ := e.client.RccpV1alpha1().PatroniClusters("").List(context.Background(), metav1.ListOptions{})
+	for _, obj := range clusterList.Items {
+		clusters = append(clusters, obj)
+	}
+	e.backups.Replace(backups, "")
+	e.clusters.Replace(clusters, "")
+}
+
+func (e *backupTestEnv) createCluster(pCluster *clusterv1alpha1.PatroniCluster) {
+	if _, err := e.client.RccpV1alpha1().PatroniClusters(pCluster.Namespace).Create(context.Background(), pCluster, metav1.CreateOptions{}); err != nil {
+		e.t.Fatalf("create patroni cluster failed: %v", err)
+	}
+}
+
+func (e *backupTestEnv) createBackup(name string, backupType clusterv1alpha1.BackupType) {
+	backup := &clusterv1alpha1.PatroniBackup{
+		ObjectMeta:        metav1.ObjectMeta{Name: name, Namespace: "default"},
+		PatroniBackupSpec: clusterv1alpha1.PatroniBackupSpec{ClusterName: "pg", Type: backupType},
+	}
+	if _, err := e.client.RccpV1alpha1().PatroniBackups("default").Create(context.Background(), backup, metav1.CreateOptions{}); err != nil {
+		e.t.Fatalf("create patroni backup failed: %v", err)
+	}
+}
+
+func (e *backupTestEnv) getBackup(name string) *clusterv1alpha1.PatroniBackup {
+	backup, err := e.client.RccpV1alpha1().PatroniBackups("default").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		e.t.Fatalf("get patroni backup failed: %v", err)
+	}
+	return backup
+}
+
+// reconcile 刷新缓存后调谐一次备份
+func (e *backupTestEnv) reconcile(name string) time.Duration {
+	e.refresh()
+	result, err := e.c.handleBackup("default/" + name)
+	if err != nil {
+		e.t.Fatalf("handle backup %s failed: %v", name, err)
+	}
+	return result.RequeueAfter
+}
+
+func newBackupCluster() *clusterv1alpha1.PatroniCluster {
+	pCluster := newTestCluster("a", "b")
+	pCluster.PatroniClusterSpec.BackupStorage = &clusterv1alpha1.BackupStorageSpec{
+		S3: clusterv1alpha1.S3Storage{Bucket: "backup", Endpoint: "http://minio:9000", CredentialsSecret: "minio"},
+	}
+	return pCluster
+}
+
+// newBackupPod 集群成员 Pod，容器在 startedAt 启动
+func newBackupPod(name, role string, startedAt time.Time) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"application": "patroni", "cluster-name": "pg", patroniRoleLabel: role},
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  postgresContainerName,
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(startedAt)}},
+			}},
+		},
+	}
+}
+
+// TestBackupLifecycle 备份在 replica 上执行，完成后记录对象存储中的备份，删除备份对象时从对象存储删除备份数据
+func TestBackupLifecycle(t *testing.T) {
+
+	env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(-time.Hour)), newBackupPod("pg-b-0", patroniReplicaRole, time.Now().Add(-time.Hour)))
+	env.createCluster(newBackupCluster())
+	env.createBackup("full", clusterv1alpha1.BackupFull)
+
+	// 第一次调谐只添加 Finalizer
+	backup := env.getBackup("full")
+	backup.Finalizers = []string{patroniBackupFinalizerStr}
+	if _, err := env.client.RccpV1alpha1().PatroniBackups("default").Update(context.Background(), backup, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("add finalizer failed: %v", err)
+	}
+
+	env.reconcile("full")
+	backup = env.getBackup("full")
+	if backup.PatroniBackupStatus.Phase != clusterv1alpha1.BackupRunning || backup.PatroniBackupStatus.Member != "pg-b-0" || backup.PatroniBackupStatus.StartTime == nil {
+		t.Fatalf("expected running backup on pg-b-0, got %+v", backup.PatroniBackupStatus)
+	}
+	if fmt.Sprint(env.walg.pushes) != "[full]" {
+		t.Fatalf("expected a single backup-push, got %v", env.walg.pushes)
+	}
+
+	// lister 缓存仍是启动之前的对象，状态写回冲突，不会再次启动备份
+	if _, err := env.c.handleBackup("default/full"); !k8serrors.IsConflict(err) {
+		t.Fatalf("expected conflict with stale cache, got %v", err)
+	}
+	if len(env.walg.pushes) != 1 {
+		t.Fatalf("expected a single backup-push, got %v", env.walg.pushes)
+	}
+
+	if requeueAfter := env.reconcile("full"); requeueAfter != backupPollInterval {
+		t.Fatalf("expected poll while backup is running, got %v", requeueAfter)
+	}
+
+	env.walg.finish("full", 0, "base_000000010000000000000002")
+	env.reconcile("full")
+	status := env.getBackup("full").PatroniBackupStatus
+	if status.Phase != clusterv1alpha1.BackupCompleted || status.BackupName != "base_000000010000000000000002" ||
+		status.Size != "4.0MiB" || status.StartLSN != "0/2000028" || status.EndWAL != "000000010000000000000002" {
+		t.Fatalf("unexpected completed status %+v", status)
+	}
+	if len(env.walg.files) != 0 {
+		t.Errorf("expected backup files cleaned up, got %v", env.walg.files)
+	}
+
+	if err := env.client.RccpV1alpha1().PatroniBackups("default").Delete(context.Background(), "full", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete patroni backup failed: %v", err)
+	}
+	env.reconcile("full")
+	if fmt.Sprint(env.walg.deletes) != "[base_000000010000000000000002]" || len(env.walg.backups) != 0 {
+		t.Errorf("expected backup deleted from backup storage, got deletes %v backups %v", env.walg.deletes, env.walg.backups)
+	}
+	if env.getBackup("full") != nil {
+		t.Errorf("expected patroni backup removed after cleanup")
+	}
+}
+
+// TestBackupRelaunch Running 状态保存后备份进程没有启动时重新启动，已经启动的备份不会重复执行
+func TestBackupRelaunch(t *testing.T) {
+
+	env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(-time.Hour)))
+	env.createCluster(newBackupCluster())
+	env.createBackup("full", clusterv1alpha1.BackupFull)
+
+	env.walg.failLaunch = true
+	env.reconcile("full")
+	backup := env.getBackup("full")
+	if backup.PatroniBackupStatus.Phase != clusterv1alpha1.BackupRunning || len(env.walg.pushes) != 0 {
+		t.Fatalf("expected running backup without process, got %s %v", backup.PatroniBackupStatus.Phase, env.walg.pushes)
+	}
+	if fmt.Sprint(backup.Finalizers) != "[patroni-backup-controller]" {
+		t.Fatalf("expected finalizer %s, got %v", patroniBackupFinalizerStr, backup.Finalizers)
+	}
+
+	env.reconcile("full")
+	if fmt.Sprint(env.walg.pushes) != "[full]" {
+		t.Fatalf("expected backup relaunched, got %v", env.walg.pushes)
+	}
+
+	// 再次执行启动脚本时备份进程已经存在
+	if err := env.c.launchBackup(env.getBackup("full"), newBackupPod("pg-a-0", "master", time.Now())); err != nil {
+		t.Fatalf("launch backup failed: %v", err)
+	}
+	env.reconcile("full")
+	if len(env.walg.pushes) != 1 {
+		t.Fatalf("expected a single backup-push, got %v", env.walg.pushes)
+	}
+	if phase := env.getBackup("full").PatroniBackupStatus.Phase; phase != clusterv1alpha1.BackupRunning {
+		t.Errorf("expected running backup, got %s", phase)
+	}
+}
+
+// TestBackupMemberRestarted 成员容器在备份开始后重启时备份失败
+func TestBackupMemberRestarted(t *testing.T) {
+
+	env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(time.Hour)))
+	env.createCluster(newBackupCluster())
+	env.createBackup("full", clusterv1alpha1.BackupFull)
+
+	env.walg.failLaunch = true
+	env.reconcile("full")
+	env.reconcile("full")
+
+	status := env.getBackup("full").PatroniBackupStatus
+	if status.Phase != clusterv1alpha1.BackupFailed || !strings.Contains(status.Message, "member restarted") {
+		t.Errorf("expected failed backup, got %+v", status)
+	}
+	if len(env.walg.pushes) != 0 {
+		t.Errorf("expected no backup-push, got %v", env.walg.pushes)
+	}
+}
+
+// TestBackupPushFailed backup-push 失败时记录日志
+func TestBackupPushFailed(t *testing.T) {
+
+	env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(-time.Hour)))
+	env.createCluster(newBackupCluster())
+	env.createBackup("full", clusterv1alpha1.BackupFull)
+
+	env.reconcile("full")
+	env.walg.finish("full", 1, "")
+	env.reconcile("full")
+
+	status := env.getBackup("full").PatroniBackupStatus
+	if status.Phase != clusterv1alpha1.BackupFailed || !strings.Contains(status.Message, "exited with 1: ERROR: connect to postgres failed") {
+		t.Errorf("expected failed backup, got %+v", status)
+	}
+	if len(env.walg.files) != 0 {
+		t.Errorf("expected backup files cleaned up, got %v", env.walg.files)
+	}
+
+	// 失败的备份没有数据，删除时直接移除 Finalizer
+	env.client.RccpV1alpha1().PatroniBackups("default").Delete(context.Background(), "full", metav1.DeleteOptions{})
+	env.reconcile("full")
+	if env.getBackup("full") != nil || len(env.walg.deletes) != 0 {
+		t.Errorf("expected failed backup removed without deleting backup storage, deletes %v", env.walg.deletes)
+	}
+}
+
+// TestDeleteBackup 删除备份对象时对象存储中的数据按集群状态删除或保留
+func TestDeleteBackup(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// 删除之前对集群和对象存储的变更
+		prepare func(env *backupTestEnv)
+		deletes []string
+		kept    []string
+	}{
+		{
+			name:    "delete from backup storage",
+			prepare: func(env *backupTestEnv) {},
+			deletes: []string{"base_1"},
+			kept:    []string{"base_2"},
+		},
+		{
+			name: "already deleted with its full backup",
+			prepare: func(env *backupTestEnv) {
+				env.walg.backups = env.walg.backups[1:]
+			},
+			kept: []string{"base_2"},
+		},
+		{
+			name: "cluster deleted",
+			prepare: func(env *backupTestEnv) {
+				env.client.RccpV1alpha1().PatroniClusters("default").Delete(context.Background(), "pg", metav1.DeleteOptions{})
+			},
+			kept: []string{"base_1", "base_2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(-time.Hour)))
+			env.createCluster(newBackupCluster())
+			for i, name := range []string{"first", "second"} {
+				env.createBackup(name, clusterv1alpha1.BackupFull)
+				env.reconcile(name)
+				env.walg.finish(name, 0, fmt.Sprintf("base_%d", i+1))
+				env.reconcile(name)
+			}
+
+			tt.prepare(env)
+			env.client.RccpV1alpha1().PatroniBackups("default").Delete(context.Background(), "first", metav1.DeleteOptions{})
+			env.reconcile("first")
+
+			var kept []string
+			for _, b := range env.walg.backups {
+				kept = append(kept, b.BackupName)
+			}
+			sort.Strings(kept)
+			if fmt.Sprint(env.walg.deletes) != fmt.Sprint(tt.deletes) || fmt.Sprint(kept) != fmt.Sprint(tt.kept) {
+				t.Errorf("expected deletes %v kept %v, got deletes %v kept %v", tt.deletes, tt.kept, env.walg.deletes, kept)
+			}
+			if env.getBackup("first") != nil {
+				t.Errorf("expected patroni backup removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,580 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"os"
+	"pgoperator/cmd/controller/app/options"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	pgOperatorCli "pgoperator/pkg/client/clientset/versioned"
+	"pgoperator/pkg/client/clientset/versioned/scheme"
+	clusterInformer "pgoperator/pkg/client/informers/externalversions/cluster/v1alpha1"
+	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
+	"pgoperator/pkg/constants"
+	"pgoperator/pkg/utils/cron"
+	"pgoperator/pkg/utils/owner"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sort"
+	"time"
+)
+
+// 事件类型
+const (
+	reasonBackupScheduled       = "BackupScheduled"
+	reasonBackupLate            = "BackupLate"
+	reasonBackupPruned          = "BackupPruned"
+	reasonBackupPruneFailed     = "BackupPruneFailed"
+	reasonInvalidBackupSchedule = "InvalidBackupSchedule"
+)
+
+// 定时备份条件原因
+const (
+	conditionReasonBackupSucceeded = "BackupSucceeded"
+	conditionReasonBackupWaiting   = "WaitingForFirstBackup"
+	conditionReasonBackupFailed    = "BackupFailed"
+	conditionReasonBackupLate      = "BackupLate"
+	conditionReasonInvalidSchedule = "InvalidSchedule"
+)
+
+const (
+	// 定时备份的标签，值为调度名称
+	backupScheduleLabel = "backup-schedule"
+	backupClusterLabel  = "cluster-name"
+
+	defaultStartingDeadlineSeconds = 3600
+	// 没有临近的调度时，仍然周期性检查备份状态和保留策略
+	backupScheduleResync = 5 * time.Minute
+)
+
+type patroniBackupScheduleController struct {
+	eventBroadcaster record.EventBroadcaster
+	eventRecorder    record.EventRecorder
+
+	kubernetesCli kubernetes.Interface
+	restConfig    *rest.Config
+
+	pgOperatorCli pgOperatorCli.Interface
+	statusWriter  statusWriter
+	clusterLister clusterLister.PatroniClusterLister
+	clusterSynced cache.InformerSynced
+	backupLister  clusterLister.PatroniBackupLister
+	backupSynced  cache.InformerSynced
+	scheduleQueue workqueue.RateLimitingInterface
+
+	workerCount int
+	retryCount  int
+	period      time.Duration
+
+	mrgConfig *options.Config
+}
+
+func NewPatroniBackupScheduleController(kubernetesCli kubernetes.Interface, restConfig *rest.Config, pgOperatorCli pgOperatorCli.Interface,
+	clusterInformer clusterInformer.PatroniClusterInformer, backupInformer clusterInformer.PatroniBackupInformer,
+	mgrConfig *options.Config) *patroniBackupScheduleController {
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(func(format string, args ...interface{}) {
+		klog.Info(fmt.Sprintf(format, args))
+	})
+
+	controllerNamespace := os.Getenv(constants.ControllerNamespaceEnvironment)
+
+	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kubernetesCli.CoreV1().Events(controllerNamespace)})
+	r := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "patroni-backup-schedule-controller"})
+
+	c := &patroniBackupScheduleController{
+		eventBroadcaster: broadcaster,
+		eventRecorder:    r,
+		kubernetesCli:    kubernetesCli,
+		restConfig:       restConfig,
+		pgOperatorCli:    pgOperatorCli,
+		statusWriter:     newStatusWriter(pgOperatorCli),
+		clusterLister:    clusterInformer.Lister(),
+		clusterSynced:    clusterInformer.Informer().HasSynced,
+		backupLister:     backupInformer.Lister(),
+		backupSynced:     backupInformer.Informer().HasSynced,
+		scheduleQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "patroni-backup-schedule"),
+		workerCount:      1,
+		retryCount:       3,
+		period:           1 * time.Second,
+		mrgConfig:        mgrConfig,
+	}
+
+	clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueCluster(newObj)
+		},
+		AddFunc: c.enqueueCluster,
+	})
+
+	// 定时备份状态变化时重新检查所属集群
+	backupInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueBackupCluster(newObj)
+		},
+	})
+
+	return c
+}
+
+func (c *patroniBackupScheduleController) enqueueCluster(obj interface{}) {
+
+	pCluster, ok := obj.(*clusterv1alpha1.PatroniCluster)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if pCluster, ok = tombstone.Obj.(*clusterv1alpha1.PatroniCluster); !ok {
+			return
+		}
+	}
+	key, err := cache.MetaNamespaceKeyFunc(pCluster)
+	if err != nil {
+		utilruntime.HandleError(errors.Errorf("get patroni cluster key %s failed", pCluster.Name))
+		return
+	}
+	c.scheduleQueue.Add(key)
+}
+
+func (c *patroniBackupScheduleController) enqueueBackupCluster(obj interface{}) {
+
+	backup, ok := obj.(*clusterv1alpha1.PatroniBackup)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if backup, ok = tombstone.Obj.(*clusterv1alpha1.PatroniBackup); !ok {
+			return
+		}
+	}
+	if _, ok := backup.Labels[backupScheduleLabel]; !ok {
+		return
+	}
+	c.scheduleQueue.Add(fmt.Sprintf("%s/%s", backup.Namespace, backup.PatroniBackupSpec.ClusterName))
+}
+
+func (c *patroniBackupScheduleController) scheduleWork() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *patroniBackupScheduleController) processNextItem() bool {
+	key, quit := c.scheduleQueue.Get()
+
+	if quit {
+		return false
+	}
+
+	defer c.scheduleQueue.Done(key)
+
+	result, err := c.handleSchedule(key.(string))
+
+	if err != nil {
+		if c.scheduleQueue.NumRequeues(key) < c.retryCount {
+			klog.Errorf("Error syncing backup schedule of PatroniCluster %s, retrying, %v", key, err)
+			c.scheduleQueue.AddRateLimited(key)
+		} else {
+			c.scheduleQueue.Forget(key)
+			utilruntime.HandleError(err)
+		}
+		return true
+	}
+
+	if result.RequeueAfter > 0 {
+		c.scheduleQueue.Forget(key)
+		c.scheduleQueue.AddAfter(key, result.RequeueAfter)
+		return true
+	} else if result.Requeue {
+		c.scheduleQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.scheduleQueue.Forget(key)
+	return true
+}
+
+func (c *patroniBackupScheduleController) Run(ctx context.Context) error {
+	defer func() {
+		utilruntime.HandleCrash()
+		c.scheduleQueue.ShutDown()
+		klog.V(2).Infof("shutting down patroni backup schedule controller")
+	}()
+
+	klog.V(0).Infof("starting patroni backup schedule controller")
+	if !cache.WaitForCacheSync(ctx.Done(), c.clusterSynced, c.backupSynced) {
+		return errors.New("failed to wait for cached to sync")
+	}
+
+	for i := 0; i < c.workerCount; i++ {
+		go wait.Until(c.scheduleWork, c.period, ctx.Done())
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (c *patroniBackupScheduleController) Start(ctx context.Context) error {
+	return c.Run(ctx)
+}
+
+// handleSchedule 到达调度时间时创建 PatroniBackup，清理超出保留策略的备份，
+// 并根据最近的定时备份结果更新集群的 BackupHealthy 条件
+func (c *patroniBackupScheduleController) handleSchedule(key string) (ctrl.Result, error) {
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "not a valid controller key %s", key))
+		return ctrl.Result{}, err
+	}
+
+	pCluster, err := c.clusterLister.PatroniClusters(ns).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		klog.Error(errors.Wrapf(err, "Failed to get patroni-cluster object on cache %s/%s", ns, name))
+		return ctrl.Result{}, err
+	}
+
+	if pCluster.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	pCluster = pCluster.DeepCopy()
+	oldStatus := pCluster.PatroniClusterStatus.DeepCopy()
+
+	spec := pCluster.PatroniClusterSpec.BackupSchedule
+	if spec == nil {
+		pCluster.PatroniClusterStatus.BackupSchedules = nil
+		meta.RemoveStatusCondition(&pCluster.PatroniClusterStatus.Conditions, clusterv1alpha1.ConditionBackupHealthy)
+		return ctrl.Result{}, c.updateClusterStatus(pCluster, oldStatus)
+	}
+
+	backups, err := c.scheduledBackups(pCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	requeue := backupScheduleResync
+	var invalid, late []string
+	var statuses []clusterv1alpha1.BackupScheduleStatus
+
+	if pCluster.PatroniClusterSpec.BackupStorage == nil {
+		invalid = append(invalid, "spec.backupSchedule requires spec.backupStorage")
+	}
+
+	for _, s := range spec.Schedules {
+		status := scheduleStatus(pCluster, s.Name)
+
+		schedule, err := cron.Parse(s.Schedule)
+		if err == nil && schedule.Next(now.UTC()).IsZero() {
+			err = fmt.Errorf("cron expression %q never matches", s.Schedule)
+		}
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("schedule %s: %v", s.Name, err))
+			statuses = append(statuses, status)
+			continue
+		}
+		if len(invalid) != 0 || spec.Suspend {
+			status.NextScheduleTime = nil
+			statuses = append(statuses, status)
+			continue
+		}
+
+		// 首次调度从当前时间开始计算，不补做配置之前错过的备份
+		if status.NextScheduleTime == nil {
+			next := metav1.NewTime(schedule.Next(now.UTC()))
+			status.NextScheduleTime = &next
+		}
+
+		scheduledAt := status.NextScheduleTime.Time
+		if !now.Before(scheduledAt) {
+			if now.Sub(scheduledAt) > startingDeadline(spec) {
+				late = append(late, fmt.Sprintf("schedule %s missed %s", s.Name, scheduledAt.UTC().Format(time.RFC3339)))
+			}
+			// 上一个备份未结束时等待，同一集群同时只执行一个备份
+			if running := runningBackup(backups); running != "" {
+				klog.V(4).Infof("scheduled backup %s of patroni cluster %s/%s waiting for backup %s", s.Name, ns, name, running)
+				requeue = minDuration(requeue, backupPollInterval)
+				statuses = append(statuses, status)
+				continue
+			}
+
+			backup, err := c.createBackup(pCluster, s, scheduledAt)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			backups = append(backups, backup)
+			lastScheduled := metav1.NewTime(scheduledAt)
+			status.LastBackup = backup.Name
+			status.LastScheduleTime = &lastScheduled
+			next := metav1.NewTime(schedule.Next(now.UTC()))
+			status.NextScheduleTime = &next
+		}
+
+		if d := time.Until(status.NextScheduleTime.Time); d > 0 {
+			requeue = minDuration(requeue, d)
+		}
+		statuses = append(statuses, status)
+	}
+	pCluster.PatroniClusterStatus.BackupSchedules = statuses
+
+	if err := c.pruneBackups(pCluster, backups); err != nil {
+		klog.Error(err)
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonBackupPruneFailed, "prune expired backups failed: %v", err)
+	}
+
+	c.syncBackupCondition(pCluster, backups, invalid, late)
+	c.recordConditionEvent(pCluster, oldStatus)
+
+	if err := c.updateClusterStatus(pCluster, oldStatus); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// scheduledBackups 集群定时创建的备份，按创建时间升序排列
+func (c *patroniBackupScheduleController) scheduledBackups(pCluster *clusterv1alpha1.PatroniCluster) ([]*clusterv1alpha1.PatroniBackup, error) {
+
+	selector := labels.SelectorFromSet(map[string]string{backupClusterLabel: pCluster.Name})
+	all, err := c.backupLister.PatroniBackups(pCluster.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*clusterv1alpha1.PatroniBackup
+	for _, b := range all {
+		if _, ok := b.Labels[backupScheduleLabel]; ok && b.DeletionTimestamp == nil {
+			backups = append(backups, b)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreationTimestamp.Before(&backups[j].CreationTimestamp)
+	})
+
+	return backups, nil
+}
+
+// createBackup 名称由调度时间决定，状态写回失败后重试不会重复创建备份
+func (c *patroniBackupScheduleController) createBackup(pCluster *clusterv1alpha1.PatroniCluster, s clusterv1alpha1.BackupSchedule, scheduledAt time.Time) (*clusterv1alpha1.PatroniBackup, error) {
+
+	backup := &clusterv1alpha1.PatroniBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%s", pCluster.Name, s.Name, scheduledAt.UTC().Format("20060102150405")),
+			Namespace: pCluster.Namespace,
+			Labels: map[string]string{
+				backupClusterLabel:  pCluster.Name,
+				backupScheduleLabel: s.Name,
+			},
+		},
+		PatroniBackupSpec: clusterv1alpha1.PatroniBackupSpec{
+			ClusterName: pCluster.Name,
+			Type:        s.Type,
+		},
+	}
+	if backup.PatroniBackupSpec.Type == "" {
+		backup.PatroniBackupSpec.Type = clusterv1alpha1.BackupFull
+	}
+	owner.AddOwnerRef(pCluster, backup, clusterv1alpha1.SchemeGroupVersion.WithKind("PatroniCluster"))
+
+	created, err := c.pgOperatorCli.RccpV1alpha1().PatroniBackups(pCluster.Namespace).Create(context.Background(), backup, metav1.CreateOptions{})
+	if err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			return c.pgOperatorCli.RccpV1alpha1().PatroniBackups(pCluster.Namespace).Get(context.Background(), backup.Name, metav1.GetOptions{})
+		}
+		return nil, errors.Wrapf(err, "create scheduled backup %s/%s failed", backup.Namespace, backup.Name)
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonBackupScheduled, "create %s backup %s for schedule %s",
+		backup.PatroniBackupSpec.Type, backup.Name, s.Name)
+	return created, nil
+}
+
+// pruneBackups 删除超出保留策略的定时备份，备份控制器在移除 Finalizer 之前从对象存储删除备份数据，
+// 完整备份通过 wal-g delete target 删除时依赖它的增量备份同时被删除
+func (c *patroniBackupScheduleController) pruneBackups(pCluster *clusterv1alpha1.PatroniCluster, backups []*clusterv1alpha1.PatroniBackup) error {
+
+	for _, b := range expiredBackups(pCluster.PatroniClusterSpec.BackupSchedule.Retention, backups, time.Now()) {
+		err := c.pgOperatorCli.RccpV1alpha1().PatroniBackups(b.Namespace).Delete(context.Background(), b.Name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return errors.Wrapf(err, "delete patroni backup %s/%s failed", b.Namespace, b.Name)
+		}
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonBackupPruned, "prune expired backup %s", b.Name)
+	}
+
+	return nil
+}
+
+// expiredBackups 按保留策略确定最早保留的完整备份，返回在它之前创建且已经结束的定时备份，
+// backups 按创建时间升序排列
+func expiredBackups(retention *clusterv1alpha1.BackupRetention, backups []*clusterv1alpha1.PatroniBackup, now time.Time) []*clusterv1alpha1.PatroniBackup {
+
+	if retention == nil || (retention.FullBackups == nil && retention.Days == nil) {
+		return nil
+	}
+
+	var fulls []*clusterv1alpha1.PatroniBackup
+	for _, b := range backups {
+		if b.PatroniBackupSpec.Type != clusterv1alpha1.BackupIncremental && b.PatroniBackupStatus.Phase == clusterv1alpha1.BackupCompleted {
+			fulls = append(fulls, b)
+		}
+	}
+	if len(fulls) == 0 {
+		return nil
+	}
+
+	// 从最新的完整备份向前查找满足任一保留规则的最早备份，最新的完整备份始终保留
+	oldest := len(fulls) - 1
+	for i := len(fulls) - 1; i >= 0; i-- {
+		kept := len(fulls) - i
+		if retention.FullBackups != nil && int32(kept) <= *retention.FullBackups {
+			oldest = i
+		}
+		if retention.Days != nil && now.Sub(fulls[i].CreationTimestamp.Time) < time.Duration(*retention.Days)*24*time.Hour {
+			oldest = i
+		}
+	}
+	cutoff := fulls[oldest].CreationTimestamp
+
+	var expired []*clusterv1alpha1.PatroniBackup
+	for _, b := range backups {
+		if !b.CreationTimestamp.Before(&cutoff) {
+			break
+		}
+		if b.PatroniBackupStatus.Phase == clusterv1alpha1.BackupRunning || b.PatroniBackupStatus.Phase == clusterv1alpha1.BackupPending {
+			continue
+		}
+		expired = append(expired, b)
+	}
+
+	return expired
+}
+
+// syncBackupCondition 最近的定时备份失败、调度延迟或配置错误时 BackupHealthy 为 False
+func (c *patroniBackupScheduleController) syncBackupCondition(pCluster *clusterv1alpha1.PatroniCluster, backups []*clusterv1alpha1.PatroniBackup, invalid, late []string) {
+
+	if len(invalid) != 0 {
+		setCondition(pCluster, clusterv1alpha1.ConditionBackupHealthy, metav1.ConditionFalse, conditionReasonInvalidSchedule, fmt.Sprintf("%v", invalid))
+		return
+	}
+
+	// 每个调度最近一个结束的备份
+	var failed []string
+	lastFinished := map[string]*clusterv1alpha1.PatroniBackup{}
+	for _, b := range backups {
+		phase := b.PatroniBackupStatus.Phase
+		if phase == clusterv1alpha1.BackupCompleted || phase == clusterv1alpha1.BackupFailed {
+			lastFinished[b.Labels[backupScheduleLabel]] = b
+		}
+		// 长时间等待就绪成员的备份也视为延迟
+		if phase == "" || phase == clusterv1alpha1.BackupPending {
+			if time.Since(b.CreationTimestamp.Time) > startingDeadline(pCluster.PatroniClusterSpec.BackupSchedule) {
+				late = append(late, fmt.Sprintf("backup %s not started", b.Name))
+			}
+		}
+	}
+	for _, s := range pCluster.PatroniClusterSpec.BackupSchedule.Schedules {
+		if b, ok := lastFinished[s.Name]; ok && b.PatroniBackupStatus.Phase == clusterv1alpha1.BackupFailed {
+			failed = append(failed, fmt.Sprintf("backup %s failed: %s", b.Name, b.PatroniBackupStatus.Message))
+		}
+	}
+
+	switch {
+	case len(failed) != 0:
+		setCondition(pCluster, clusterv1alpha1.ConditionBackupHealthy, metav1.ConditionFalse, conditionReasonBackupFailed, fmt.Sprintf("%v", failed))
+	case len(late) != 0:
+		setCondition(pCluster, clusterv1alpha1.ConditionBackupHealthy, metav1.ConditionFalse, conditionReasonBackupLate, fmt.Sprintf("%v", late))
+	case len(lastFinished) == 0:
+		setCondition(pCluster, clusterv1alpha1.ConditionBackupHealthy, metav1.ConditionTrue, conditionReasonBackupWaiting, "")
+	default:
+		setCondition(pCluster, clusterv1alpha1.ConditionBackupHealthy, metav1.ConditionTrue, conditionReasonBackupSucceeded, "")
+	}
+}
+
+// recordConditionEvent 条件变为 False 或原因变化时在集群上记录事件，避免每次调谐重复记录
+func (c *patroniBackupScheduleController) recordConditionEvent(pCluster *clusterv1alpha1.PatroniCluster, oldStatus *clusterv1alpha1.PatroniClusterStatus) {
+
+	cond := meta.FindStatusCondition(pCluster.PatroniClusterStatus.Conditions, clusterv1alpha1.ConditionBackupHealthy)
+	if cond == nil || cond.Status != metav1.ConditionFalse {
+		return
+	}
+
+	old := meta.FindStatusCondition(oldStatus.Conditions, clusterv1alpha1.ConditionBackupHealthy)
+	if old != nil && old.Reason == cond.Reason && old.Message == cond.Message {
+		return
+	}
+
+	switch cond.Reason {
+	case conditionReasonBackupFailed:
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonBackupFailed, cond.Message)
+	case conditionReasonBackupLate:
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonBackupLate, cond.Message)
+	case conditionReasonInvalidSchedule:
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidBackupSchedule, cond.Message)
+	}
+}
+
+// updateClusterStatus 通过 status 子资源写回集群状态，与集群控制器并发写入时依赖 resourceVersion 冲突重试
+func (c *patroniBackupScheduleController) updateClusterStatus(pCluster *clusterv1alpha1.PatroniCluster, oldStatus *clusterv1alpha1.PatroniClusterStatus) error {
+
+	if equality.Semantic.DeepEqual(oldStatus, &pCluster.PatroniClusterStatus) {
+		return nil
+	}
+
+	_, err := c.statusWriter.UpdateClusterStatus(pCluster)
+	return err
+}
+
+func scheduleStatus(pCluster *clusterv1alpha1.PatroniCluster, name string) clusterv1alpha1.BackupScheduleStatus {
+	for _, s := range pCluster.PatroniClusterStatus.BackupSchedules {
+		if s.Name == name {
+			return s
+		}
+	}
+	return clusterv1alpha1.BackupScheduleStatus{Name: name}
+}
+
+func runningBackup(backups []*clusterv1alpha1.PatroniBackup) string {
+	for _, b := range backups {
+		switch b.PatroniBackupStatus.Phase {
+		case "", clusterv1alpha1.BackupPending, clusterv1alpha1.BackupRunning:
+			return b.Name
+		}
+	}
+	return ""
+}
+
+func startingDeadline(spec *clusterv1alpha1.BackupScheduleSpec) time.Duration {
+	if spec.StartingDeadlineSeconds != nil {
+		return time.Duration(*spec.StartingDeadlineSeconds) * time.Second
+	}
+	return defaultStartingDeadlineSeconds * time.Second
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,203 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"sort"
+	"testing"
+	"time"
+)
+
+type testBackup struct {
+	name       string
+	backupType clusterv1alpha1.BackupType
+	phase      clusterv1alpha1.BackupPhase
+	age        time.Duration
+}
+
+// retentionBackups 按创建时间升序排列的定时备份
+var retentionBackups = []testBackup{
+	{"full-1", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupCompleted, 20 * 24 * time.Hour},
+	{"incr-1", clusterv1alpha1.BackupIncremental, clusterv1alpha1.BackupCompleted, 19 * 24 * time.Hour},
+	{"full-2", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupCompleted, 10 * 24 * time.Hour},
+	{"full-3", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupFailed, 8 * 24 * time.Hour},
+	{"incr-2", clusterv1alpha1.BackupIncremental, clusterv1alpha1.BackupCompleted, 7 * 24 * time.Hour},
+	{"full-4", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupCompleted, 5 * 24 * time.Hour},
+	{"full-5", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupRunning, 3 * 24 * time.Hour},
+	{"full-6", clusterv1alpha1.BackupFull, clusterv1alpha1.BackupCompleted, 2 * 24 * time.Hour},
+	{"incr-3", clusterv1alpha1.BackupIncremental, clusterv1alpha1.BackupCompleted, 24 * time.Hour},
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestExpiredBackups(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		retention *clusterv1alpha1.BackupRetention
+		expired   []string
+	}{
+		{
+			name: "no retention",
+		},
+		{
+			name:      "empty retention",
+			retention: &clusterv1alpha1.BackupRetention{},
+		},
+		{
+			name:      "keep full backups",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(2)},
+			expired:   []string{"full-1", "incr-1", "full-2", "full-3", "incr-2"},
+		},
+		{
+			name:      "keep days",
+			retention: &clusterv1alpha1.BackupRetention{Days: int32Ptr(12)},
+			expired:   []string{"full-1", "incr-1"},
+		},
+		// 两个规则同时配置时，满足任一规则的完整备份都被保留
+		{
+			name:      "days keep more than full backups",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(1), Days: int32Ptr(12)},
+			expired:   []string{"full-1", "incr-1"},
+		},
+		{
+			name:      "full backups keep more than days",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(3), Days: int32Ptr(6)},
+			expired:   []string{"full-1", "incr-1"},
+		},
+		{
+			name:      "both rules keep the same backups",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(2), Days: int32Ptr(6)},
+			expired:   []string{"full-1", "incr-1", "full-2", "full-3", "incr-2"},
+		},
+		// 最新的完整备份始终保留，正在执行的备份不清理
+		{
+			name:      "latest full backup always kept",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(1), Days: int32Ptr(1)},
+			expired:   []string{"full-1", "incr-1", "full-2", "full-3", "incr-2", "full-4"},
+		},
+		{
+			name:      "retention covers all backups",
+			retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(10), Days: int32Ptr(1)},
+		},
+	}
+
+	now := time.Now()
+	var backups []*clusterv1alpha1.PatroniBackup
+	for _, tb := range retentionBackups {
+		backups = append(backups, &clusterv1alpha1.PatroniBackup{
+			ObjectMeta:          metav1.ObjectMeta{Name: tb.name, CreationTimestamp: metav1.NewTime(now.Add(-tb.age))},
+			PatroniBackupSpec:   clusterv1alpha1.PatroniBackupSpec{Type: tb.backupType},
+			PatroniBackupStatus: clusterv1alpha1.PatroniBackupStatus{Phase: tb.phase},
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expired []string
+			for _, b := range expiredBackups(tt.retention, backups, now) {
+				expired = append(expired, b.Name)
+			}
+			if fmt.Sprint(expired) != fmt.Sprint(tt.expired) {
+				t.Errorf("expected expired backups %v, got %v", tt.expired, expired)
+			}
+		})
+	}
+}
+
+// TestExpiredBackupsWithoutFull 没有完成的完整备份时不清理
+func TestExpiredBackupsWithoutFull(t *testing.T) {
+
+	backups := []*clusterv1alpha1.PatroniBackup{{
+		ObjectMeta:          metav1.ObjectMeta{Name: "full-1", CreationTimestamp: metav1.NewTime(time.Now().Add(-30 * 24 * time.Hour))},
+		PatroniBackupSpec:   clusterv1alpha1.PatroniBackupSpec{Type: clusterv1alpha1.BackupFull},
+		PatroniBackupStatus: clusterv1alpha1.PatroniBackupStatus{Phase: clusterv1alpha1.BackupFailed},
+	}}
+
+	if expired := expiredBackups(&clusterv1alpha1.BackupRetention{Days: int32Ptr(1)}, backups, time.Now()); len(expired) != 0 {
+		t.Errorf("expected no expired backups, got %v", expired)
+	}
+}
+
+// TestPruneBackups 清理的备份对象删除后，备份控制器从对象存储删除对应的备份数据
+func TestPruneBackups(t *testing.T) {
+
+	env := newBackupTestEnv(t, newBackupPod("pg-a-0", "master", time.Now().Add(-time.Hour)))
+	pCluster := newBackupCluster()
+	pCluster.PatroniClusterSpec.BackupSchedule = &clusterv1alpha1.BackupScheduleSpec{
+		Retention: &clusterv1alpha1.BackupRetention{FullBackups: int32Ptr(1), Days: int32Ptr(12)},
+	}
+	env.createCluster(pCluster)
+
+	now := time.Now()
+	for _, tb := range retentionBackups {
+		backup := &clusterv1alpha1.PatroniBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              tb.name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-tb.age)),
+				Labels:            map[string]string{backupClusterLabel: "pg", backupScheduleLabel: "daily"},
+				Finalizers:        []string{patroniBackupFinalizerStr},
+			},
+			PatroniBackupSpec: clusterv1alpha1.PatroniBackupSpec{ClusterName: "pg", Type: tb.backupType},
+		}
+		backup, err := env.client.RccpV1alpha1().PatroniBackups("default").Create(context.Background(), backup, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("create patroni backup failed: %v", err)
+		}
+		backup.PatroniBackupStatus.Phase = tb.phase
+		if tb.phase == clusterv1alpha1.BackupCompleted {
+			backup.PatroniBackupStatus.BackupName = "base_" + tb.name
+			env.walg.finish(tb.name, 0, backup.PatroniBackupStatus.BackupName)
+		}
+		if err := env.c.updateBackupStatus(backup); err != nil {
+			t.Fatalf("update patroni backup status failed: %v", err)
+		}
+	}
+	env.walg.files = map[string]string{}
+
+	env.refresh()
+	c := &patroniBackupScheduleController{
+		eventRecorder: record.NewFakeRecorder(100),
+		pgOperatorCli: env.client,
+		statusWriter:  env.c.statusWriter,
+		backupLister:  env.c.backupLister,
+	}
+	backups, err := c.scheduledBackups(pCluster)
+	if err != nil {
+		t.Fatalf("list scheduled backups failed: %v", err)
+	}
+	if err := c.pruneBackups(pCluster, backups); err != nil {
+		t.Fatalf("prune backups failed: %v", err)
+	}
+
+	for _, tb := range retentionBackups {
+		env.reconcile(tb.name)
+	}
+
+	var kept, stored []string
+	for _, tb := range retentionBackups {
+		if env.getBackup(tb.name) != nil {
+			kept = append(kept, tb.name)
+		}
+	}
+	for _, b := range env.walg.backups {
+		stored = append(stored, b.UserData.Name)
+	}
+	sort.Strings(stored)
+
+	if expected := "[full-2 full-3 incr-2 full-4 full-5 full-6 incr-3]"; fmt.Sprint(kept) != expected {
+		t.Errorf("expected patroni backups %s kept, got %v", expected, kept)
+	}
+	if expected := "[full-2 full-4 full-6 incr-2 incr-3]"; fmt.Sprint(stored) != expected {
+		t.Errorf("expected backups %s in backup storage, got %v", expected, stored)
+	}
+	if fmt.Sprint(env.walg.deletes) != "[base_full-1 base_incr-1]" {
+		t.Errorf("expected expired backups deleted from backup storage, got %v", env.walg.deletes)
+	}
+}
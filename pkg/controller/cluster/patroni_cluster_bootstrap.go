@@ -0,0 +1,447 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/utils/owner"
+	"reflect"
+	"strings"
+)
+
+// 事件类型
+const (
+	reasonBootstrapRecovery = "BootstrapRecovery"
+	reasonBootstrapClone    = "BootstrapClone"
+	reasonInvalidBootstrap  = "InvalidBootstrap"
+)
+
+const (
+	recoveryBootstrapMethod = "walg_recovery"
+	cloneBootstrapMethod    = "pg_basebackup_clone"
+	// 恢复源对象存储的环境变量前缀，避免与本集群的备份存储冲突
+	recoveryEnvPrefix = "RECOVERY_"
+	latestBackupName  = "LATEST"
+)
+
+// customBootstrapScript 自定义 bootstrap 命令，Patroni 在命令后追加 --scope 和 --datadir 参数
+func customBootstrapScript(method string, steps ...string) string {
+	steps = append([]string{
+		`for arg in "$@"; do case "$arg" in --datadir=*) datadir="${arg#--datadir=}";; esac; done`,
+	}, steps...)
+	return fmt.Sprintf("sh -c '%s' %s", strings.Join(steps, " && "), method)
+}
+
+// customBootstrapConfig 返回自定义 bootstrap 方法名和配置，未配置时返回空
+func customBootstrapConfig(pCluster *clusterv1alpha1.PatroniCluster) (string, map[string]interface{}) {
+	if config := recoveryBootstrap(pCluster); config != nil {
+		return recoveryBootstrapMethod, config
+	}
+	if config := cloneBootstrap(pCluster); config != nil {
+		return cloneBootstrapMethod, config
+	}
+	return "", nil
+}
+
+// bootstrapEnv 自定义 bootstrap 访问数据源使用的环境变量
+func bootstrapEnv(pCluster *clusterv1alpha1.PatroniCluster) []v1.EnvVar {
+	return append(recoveryEnv(pCluster), cloneEnv(pCluster)...)
+}
+
+// customBootstrap 集群是否通过自定义 bootstrap 初始化
+func customBootstrap(pCluster *clusterv1alpha1.PatroniCluster) bool {
+	bootstrap := pCluster.PatroniClusterSpec.Bootstrap
+	return bootstrap != nil && (bootstrap.Recovery != nil || bootstrap.CloneFrom != nil)
+}
+
+func bootstrapRecovery(pCluster *clusterv1alpha1.PatroniCluster) *clusterv1alpha1.RecoverySpec {
+	if pCluster.PatroniClusterSpec.Bootstrap == nil {
+		return nil
+	}
+	return pCluster.PatroniClusterSpec.Bootstrap.Recovery
+}
+
+func bootstrapClone(pCluster *clusterv1alpha1.PatroniCluster) *clusterv1alpha1.CloneSpec {
+	if pCluster.PatroniClusterSpec.Bootstrap == nil {
+		return nil
+	}
+	return pCluster.PatroniClusterSpec.Bootstrap.CloneFrom
+}
+
+// resolveBootstrap 在第一个成员完成 bootstrap 之前校验数据源
+func (c *patroniClusterController) resolveBootstrap(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+
+	pending, err := c.bootstrapPending(pCluster)
+	if err != nil || !pending {
+		return pCluster, err
+	}
+
+	recovery, clone := bootstrapRecovery(pCluster), bootstrapClone(pCluster)
+	if recovery != nil && clone != nil {
+		return pCluster, fmt.Errorf("only one of recovery and cloneFrom can be specified in spec.bootstrap")
+	}
+	if clone != nil {
+		return pCluster, c.resolveClone(pCluster)
+	}
+	return c.resolveRecovery(pCluster)
+}
+
+// resolveRecovery recovery 引用 PatroniBackup 时将备份位置写入 spec，
+// 之后成员模板和 Patroni 配置只依赖 spec 生成，不受备份对象被清理的影响
+func (c *patroniClusterController) resolveRecovery(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+
+	recovery := bootstrapRecovery(pCluster)
+	targets := 0
+	for _, set := range []bool{recovery.TargetTime != nil, recovery.TargetLSN != "", recovery.TargetName != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets > 1 {
+		return pCluster, fmt.Errorf("only one of targetTime, targetLSN and targetName can be specified in spec.bootstrap.recovery")
+	}
+
+	ns := pCluster.Namespace
+	if recovery.Source == nil {
+		if recovery.Backup == "" {
+			return pCluster, fmt.Errorf("one of backup and source must be specified in spec.bootstrap.recovery")
+		}
+
+		backup, err := c.pgOperatorCli.RccpV1alpha1().PatroniBackups(ns).Get(context.Background(), recovery.Backup, metav1.GetOptions{})
+		if err != nil {
+			return pCluster, errors.Wrapf(err, "get patroni backup %s/%s failed", ns, recovery.Backup)
+		}
+		if backup.PatroniBackupStatus.Phase != clusterv1alpha1.BackupCompleted {
+			return pCluster, fmt.Errorf("patroni backup %s/%s is not completed", ns, recovery.Backup)
+		}
+		source, err := c.clusterLister.PatroniClusters(ns).Get(backup.PatroniBackupSpec.ClusterName)
+		if err != nil {
+			return pCluster, errors.Wrapf(err, "get source cluster of patroni backup %s/%s failed, specify spec.bootstrap.recovery.source instead", ns, recovery.Backup)
+		}
+		if source.PatroniClusterSpec.BackupStorage == nil {
+			return pCluster, fmt.Errorf("source cluster %s/%s has no backup storage", ns, source.Name)
+		}
+
+		s3 := source.PatroniClusterSpec.BackupStorage.S3
+		s3.Path = strings.TrimPrefix(backupPrefix(source), fmt.Sprintf("s3://%s/", s3.Bucket))
+
+		updated, err := c.patchSpec(pCluster, map[string]interface{}{
+			"bootstrap": map[string]interface{}{
+				"recovery": map[string]interface{}{
+					"source":     s3,
+					"backupName": backup.PatroniBackupStatus.BackupName,
+				},
+			},
+		})
+		if err != nil {
+			return pCluster, errors.Wrapf(err, "update recovery source of patroni cluster %s/%s failed", ns, pCluster.Name)
+		}
+		pCluster = updated
+		recovery = bootstrapRecovery(pCluster)
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonBootstrapRecovery, "recover from backup %s (%s) of cluster %s",
+			recovery.Backup, recovery.BackupName, source.Name)
+	}
+
+	if strings.Trim(recovery.Source.Path, "/") == "" {
+		return pCluster, fmt.Errorf("spec.bootstrap.recovery.source.path is required")
+	}
+	if sameBackupLocation(pCluster, *recovery.Source, s3Prefix(*recovery.Source, "")) {
+		return pCluster, fmt.Errorf("spec.backupStorage %s is the recovery source, specify a different spec.backupStorage.s3.path", backupPrefix(pCluster))
+	}
+
+	return pCluster, c.checkS3Credentials(ns, recovery.Source.CredentialsSecret, "spec.bootstrap.recovery.source.credentialsSecret")
+}
+
+// sameBackupLocation 集群的备份存储与数据源的备份位置相同时，新集群归档的 WAL 和备份会覆盖数据源的时间线
+func sameBackupLocation(pCluster *clusterv1alpha1.PatroniCluster, source clusterv1alpha1.S3Storage, sourcePrefix string) bool {
+
+	storage := pCluster.PatroniClusterSpec.BackupStorage
+	if storage == nil || strings.TrimRight(storage.S3.Endpoint, "/") != strings.TrimRight(source.Endpoint, "/") {
+		return false
+	}
+	return backupPrefix(pCluster) == sourcePrefix
+}
+
+// bootstrapPending 自定义 bootstrap 的集群在 leader 就绪之前只创建第一个成员，
+// 其余成员之后通过 basebackup 从 leader 复制
+func (c *patroniClusterController) bootstrapPending(pCluster *clusterv1alpha1.PatroniCluster) (bool, error) {
+
+	if !customBootstrap(pCluster) {
+		return false, nil
+	}
+	if status := pCluster.PatroniClusterStatus.Status; status != "" && status != clusterv1alpha1.ClusterInit {
+		return false, nil
+	}
+
+	selector := labels.SelectorFromSet(map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}).String()
+	pods, err := c.kubernetesCli.CoreV1().Pods(pCluster.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return false, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if patroniLeaderRoles.Has(pod.Labels[patroniRoleLabel]) && isPodReady(pod) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// recoveryEnv 恢复源对象存储的环境变量，使用 recoveryEnvPrefix 前缀
+func recoveryEnv(pCluster *clusterv1alpha1.PatroniCluster) []v1.EnvVar {
+
+	recovery := bootstrapRecovery(pCluster)
+	if recovery == nil || recovery.Source == nil {
+		return nil
+	}
+
+	source := *recovery.Source
+	return s3Env(recoveryEnvPrefix, s3Prefix(source, ""), source)
+}
+
+// recoveryWalgCommand 使用恢复源对象存储执行 wal-g，环境变量在 shell 中展开
+func recoveryWalgCommand(source *clusterv1alpha1.S3Storage) string {
+
+	vars := []string{"WALG_S3_PREFIX", "AWS_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+	if source.Endpoint != "" {
+		vars = append(vars, "AWS_ENDPOINT")
+	}
+	if source.ForcePathStyle {
+		vars = append(vars, "AWS_S3_FORCE_PATH_STYLE")
+	}
+
+	command := []string{"env"}
+	for _, v := range vars {
+		command = append(command, fmt.Sprintf(`%s="$%s%s"`, v, recoveryEnvPrefix, v))
+	}
+	return strings.Join(append(command, "wal-g"), " ")
+}
+
+// recoveryBootstrap Patroni 自定义 bootstrap 配置：通过 wal-g 取回基础备份，
+// 再由 recovery_conf 从恢复源重放 WAL，到达恢复目标后提升为 leader
+func recoveryBootstrap(pCluster *clusterv1alpha1.PatroniCluster) map[string]interface{} {
+
+	recovery := bootstrapRecovery(pCluster)
+	if recovery == nil || recovery.Source == nil {
+		return nil
+	}
+
+	walg := recoveryWalgCommand(recovery.Source)
+	backupName := recovery.BackupName
+	if backupName == "" {
+		backupName = latestBackupName
+	}
+
+	script := []string{
+		fmt.Sprintf(`%s backup-fetch "$datadir" %s`, walg, backupName),
+	}
+	// 备份中的 pg_wal 是普通目录，移动到独立的 WAL 数据卷
+	if hasWALVolume(pCluster) {
+		script = append(script, fmt.Sprintf(`if [ ! -L "$datadir/pg_wal" ]; then mkdir -p %[1]s && cp -a "$datadir/pg_wal/." %[1]s/ && rm -rf "$datadir/pg_wal" && ln -s %[1]s "$datadir/pg_wal"; fi`, defaultPgWalPath))
+	}
+
+	recoveryConf := map[string]interface{}{
+		"restore_command":          walg + " wal-fetch %f %p",
+		"recovery_target_action":   "promote",
+		"recovery_target_timeline": "latest",
+	}
+	switch {
+	case recovery.TargetTime != nil:
+		recoveryConf["recovery_target_time"] = recovery.TargetTime.UTC().Format("2006-01-02 15:04:05-07:00")
+	case recovery.TargetLSN != "":
+		recoveryConf["recovery_target_lsn"] = recovery.TargetLSN
+	case recovery.TargetName != "":
+		recoveryConf["recovery_target_name"] = recovery.TargetName
+	}
+
+	return map[string]interface{}{
+		"command":                     customBootstrapScript(recoveryBootstrapMethod, script...),
+		"keep_existing_recovery_conf": false,
+		"recovery_conf":               recoveryConf,
+	}
+}
+
+// cloneSourceSecretName 保存源集群复制用户的 Secret，Pod 不能引用其他命名空间中的 Secret
+func cloneSourceSecretName(pCluster *clusterv1alpha1.PatroniCluster) string {
+	return fmt.Sprintf("%s-clone-source", pCluster.Name)
+}
+
+func cloneSourceNamespace(pCluster *clusterv1alpha1.PatroniCluster) string {
+	if ns := bootstrapClone(pCluster).Namespace; ns != "" {
+		return ns
+	}
+	return pCluster.Namespace
+}
+
+// resolveClone 检查源集群可以提供复制，并将源集群复制用户的凭证复制到新集群的命名空间
+func (c *patroniClusterController) resolveClone(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	clone := bootstrapClone(pCluster)
+	ns := cloneSourceNamespace(pCluster)
+	if ns == pCluster.Namespace && clone.ClusterName == pCluster.Name {
+		return fmt.Errorf("patroni cluster can not clone from itself")
+	}
+
+	source, err := c.clusterLister.PatroniClusters(ns).Get(clone.ClusterName)
+	if err != nil {
+		return errors.Wrapf(err, "get source cluster %s/%s failed", ns, clone.ClusterName)
+	}
+	if source.PatroniClusterSpec.ReplicationUserSecretName == "" {
+		return fmt.Errorf("source cluster %s/%s has no replication credentials", ns, source.Name)
+	}
+	if storage := source.PatroniClusterSpec.BackupStorage; storage != nil && sameBackupLocation(pCluster, storage.S3, backupPrefix(source)) {
+		return fmt.Errorf("spec.backupStorage %s is the backup storage of source cluster %s/%s, specify a different spec.backupStorage.s3.path",
+			backupPrefix(pCluster), ns, source.Name)
+	}
+
+	role := leaderLabelValue(source)
+	if clone.FromReplica {
+		role = patroniReplicaRole
+	}
+	pods, err := c.kubernetesCli.CoreV1().Pods(ns).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"application":    "patroni",
+			"cluster-name":   source.Name,
+			patroniRoleLabel: role,
+		}).String(),
+	})
+	if err != nil {
+		return err
+	}
+	ready := false
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			ready = true
+			break
+		}
+	}
+	if !ready {
+		return fmt.Errorf("source cluster %s/%s has no ready %s", ns, source.Name, role)
+	}
+
+	secret, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), source.PatroniClusterSpec.ReplicationUserSecretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "get replication secret of source cluster %s/%s failed", ns, source.Name)
+	}
+
+	data := map[string][]byte{
+		secretUsernameKey: []byte(replicationUserName(source)),
+		secretPasswordKey: secret.Data[secretPasswordKey],
+	}
+	name := cloneSourceSecretName(pCluster)
+	existing, err := c.kubernetesCli.CoreV1().Secrets(pCluster.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err == nil {
+		if !owner.HasOwnerRef(pCluster, existing) {
+			return fmt.Errorf("secret %s/%s already exists and is not owned by patroni cluster %s", pCluster.Namespace, name, pCluster.Name)
+		}
+		if reflect.DeepEqual(existing.Data, data) {
+			return nil
+		}
+		existing = existing.DeepCopy()
+		existing.Data = data
+		_, err = c.kubernetesCli.CoreV1().Secrets(pCluster.Namespace).Update(context.Background(), existing, metav1.UpdateOptions{})
+		return errors.Wrapf(err, "update secret %s/%s failed", pCluster.Namespace, name)
+	}
+	if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	cloneSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pCluster.Namespace,
+			Labels: map[string]string{
+				"application":  "patroni",
+				"cluster-name": pCluster.Name,
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+	owner.AddOwnerRef(pCluster, cloneSecret, clusterv1alpha1.SchemeGroupVersion.WithKind("PatroniCluster"))
+	if _, err := c.kubernetesCli.CoreV1().Secrets(pCluster.Namespace).Create(context.Background(), cloneSecret, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "create secret %s/%s failed", pCluster.Namespace, name)
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonBootstrapClone, "clone from %s of cluster %s/%s", role, ns, source.Name)
+	return nil
+}
+
+// cloneEnv 连接源集群使用的复制用户，bootstrap 完成后不再需要，Secret 被删除时不影响成员启动
+func cloneEnv(pCluster *clusterv1alpha1.PatroniCluster) []v1.EnvVar {
+
+	if bootstrapClone(pCluster) == nil {
+		return nil
+	}
+
+	optional := true
+	env := []v1.EnvVar{
+		secretEnv("CLONE_USERNAME", cloneSourceSecretName(pCluster), secretUsernameKey),
+		secretEnv("CLONE_PASSWORD", cloneSourceSecretName(pCluster), secretPasswordKey),
+	}
+	for i := range env {
+		env[i].ValueFrom.SecretKeyRef.Optional = &optional
+	}
+	return env
+}
+
+// cloneBootstrap Patroni 自定义 bootstrap 配置：通过 pg_basebackup 从源集群的服务复制数据目录，
+// 再将数据目录中的超级用户和复制用户密码修改为新集群的凭证
+func cloneBootstrap(pCluster *clusterv1alpha1.PatroniCluster) map[string]interface{} {
+
+	clone := bootstrapClone(pCluster)
+	if clone == nil {
+		return nil
+	}
+
+	service := primaryServiceName(clone.ClusterName)
+	if clone.FromReplica {
+		service = replicasServiceName(clone.ClusterName)
+	}
+	basebackup := fmt.Sprintf(`PGPASSWORD="$CLONE_PASSWORD" pg_basebackup -D "$datadir" -h %s.%s.svc -p 5432 -U "$CLONE_USERNAME" -X stream -c fast -w`,
+		service, cloneSourceNamespace(pCluster))
+	if hasWALVolume(pCluster) {
+		basebackup += " --waldir=" + defaultPgWalPath
+	}
+
+	return map[string]interface{}{
+		"command":                     customBootstrapScript(cloneBootstrapMethod, basebackup, cloneCredentialsScript()),
+		"keep_existing_recovery_conf": false,
+	}
+}
+
+// cloneCredentialsScript 克隆的数据目录中是源集群的用户和密码，本地连接使用 md5 认证，
+// Patroni 启动后使用新集群的超级用户连接数据库，因此在启动之前以单用户模式修改密码，
+// 用户不存在时先创建；没有单独的 rewind 用户，pg_rewind 使用超级用户。
+// 脚本位于 sh -c 的单引号中，用户名和密码从环境变量作为 printf 参数传入，SQL 中使用 dollar quote
+func cloneCredentialsScript() string {
+
+	createRole := `DO $do$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $n$%s$n$) THEN CREATE ROLE "%s" WITH LOGIN; END IF; END $do$;`
+	statements := []string{
+		createRole,
+		`ALTER ROLE "%s" WITH SUPERUSER LOGIN PASSWORD $pw$%s$pw$;`,
+		createRole,
+		`ALTER ROLE "%s" WITH REPLICATION LOGIN PASSWORD $pw$%s$pw$;`,
+	}
+	args := []string{
+		"SUPERUSER_USERNAME", "SUPERUSER_USERNAME", "SUPERUSER_USERNAME", "SUPERUSER_PASSWORD",
+		"REPLICATION_USERNAME", "REPLICATION_USERNAME", "REPLICATION_USERNAME", "REPLICATION_PASSWORD",
+	}
+	for i, arg := range args {
+		args[i] = fmt.Sprintf(`"$PATRONI_%s"`, arg)
+	}
+
+	// 单用户模式中每行是一条语句
+	format := strings.NewReplacer(`$`, `\$`, `"`, `\"`).Replace(strings.Join(statements, `\n`) + `\n`)
+	return fmt.Sprintf(`printf "%s" %s | postgres --single -D "$datadir" -c log_statement=none postgres >/dev/null`,
+		format, strings.Join(args, " "))
+}
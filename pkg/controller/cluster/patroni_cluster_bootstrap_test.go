@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"context"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"os"
+	"os/exec"
+	"path/filepath"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
+	"strings"
+	"testing"
+)
+
+func TestSameBackupLocation(t *testing.T) {
+
+	minio := "http://minio:9000"
+	tests := []struct {
+		name    string
+		storage *clusterv1alpha1.BackupStorageSpec
+		source  clusterv1alpha1.S3Storage
+		same    bool
+	}{
+		{
+			name:   "no backup storage",
+			source: clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "default/pg"},
+		},
+		{
+			name:    "default path",
+			storage: &clusterv1alpha1.BackupStorageSpec{S3: clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup"}},
+			source:  clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "default/pg"},
+			same:    true,
+		},
+		{
+			name:    "explicit path",
+			storage: &clusterv1alpha1.BackupStorageSpec{S3: clusterv1alpha1.S3Storage{Endpoint: minio + "/", Bucket: "backup", Path: "/source/"}},
+			source:  clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "source"},
+			same:    true,
+		},
+		{
+			name:    "different path",
+			storage: &clusterv1alpha1.BackupStorageSpec{S3: clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup"}},
+			source:  clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "default/source"},
+		},
+		{
+			name:    "different bucket",
+			storage: &clusterv1alpha1.BackupStorageSpec{S3: clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "restore"}},
+			source:  clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "default/pg"},
+		},
+		{
+			name:    "different endpoint",
+			storage: &clusterv1alpha1.BackupStorageSpec{S3: clusterv1alpha1.S3Storage{Endpoint: "http://minio-dr:9000", Bucket: "backup"}},
+			source:  clusterv1alpha1.S3Storage{Endpoint: minio, Bucket: "backup", Path: "default/pg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pCluster := newTestCluster("a")
+			pCluster.PatroniClusterSpec.BackupStorage = tt.storage
+			if same := sameBackupLocation(pCluster, tt.source, s3Prefix(tt.source, "")); same != tt.same {
+				t.Errorf("expected same backup location %v, got %v", tt.same, same)
+			}
+		})
+	}
+}
+
+// TestResolveRecovery 从备份对象恢复时只写回 source 和 backupName，保留 spec 中的其他字段
+func TestResolveRecovery(t *testing.T) {
+
+	client := newFakePgOperatorCli()
+	source := newBackupCluster()
+	source.Name = "source"
+
+	backup, err := client.RccpV1alpha1().PatroniBackups("default").Create(context.Background(), &clusterv1alpha1.PatroniBackup{
+		ObjectMeta:        metav1.ObjectMeta{Name: "full", Namespace: "default"},
+		PatroniBackupSpec: clusterv1alpha1.PatroniBackupSpec{ClusterName: source.Name, Type: clusterv1alpha1.BackupFull},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create patroni backup failed: %v", err)
+	}
+	backup.PatroniBackupStatus = clusterv1alpha1.PatroniBackupStatus{Phase: clusterv1alpha1.BackupCompleted, BackupName: "base_000000010000000000000002"}
+	if _, err := (&fakeStatusWriter{client: client}).UpdateBackupStatus(backup); err != nil {
+		t.Fatalf("update patroni backup status failed: %v", err)
+	}
+
+	targetTime := metav1.Now()
+	pCluster := newTestCluster("a")
+	pCluster.PatroniClusterSpec.Bootstrap = &clusterv1alpha1.BootstrapSpec{
+		Recovery: &clusterv1alpha1.RecoverySpec{Backup: "full", TargetTime: &targetTime},
+	}
+	if pCluster, err = client.RccpV1alpha1().PatroniClusters("default").Create(context.Background(), pCluster, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create patroni cluster failed: %v", err)
+	}
+
+	clusters := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	clusters.Add(source)
+	c := &patroniClusterController{
+		kubernetesCli: fake.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "minio", Namespace: "default"},
+			Data:       map[string][]byte{backupAccessKeyIdKey: []byte("minio"), backupSecretAccessKeyKey: []byte("minio123")},
+		}),
+		pgOperatorCli: client,
+		clusterLister: clusterLister.NewPatroniClusterLister(clusters),
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+
+	if _, err := c.resolveRecovery(pCluster); err != nil {
+		t.Fatalf("resolve recovery failed: %v", err)
+	}
+
+	updated, err := client.RccpV1alpha1().PatroniClusters("default").Get(context.Background(), pCluster.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get patroni cluster failed: %v", err)
+	}
+	recovery := bootstrapRecovery(updated)
+	if recovery.Source == nil || recovery.Source.Path != "default/source" || recovery.BackupName != "base_000000010000000000000002" {
+		t.Errorf("expected recovery source of backup full, got %+v", recovery)
+	}
+	if recovery.Backup != "full" || recovery.TargetTime == nil {
+		t.Errorf("expected other recovery fields kept, got %+v", recovery)
+	}
+}
+
+// TestCloneBootstrapCredentials 克隆完成后以单用户模式将超级用户和复制用户的密码修改为新集群的凭证
+func TestCloneBootstrapCredentials(t *testing.T) {
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	pCluster := newTestCluster("a")
+	pCluster.PatroniClusterSpec.Bootstrap = &clusterv1alpha1.BootstrapSpec{CloneFrom: &clusterv1alpha1.CloneSpec{ClusterName: "source"}}
+	command := cloneBootstrap(pCluster)["command"].(string)
+
+	// pg_basebackup 和 postgres 替身，postgres 记录参数和标准输入
+	dir := t.TempDir()
+	stubs := map[string]string{
+		"pg_basebackup": "#!/bin/sh\nexit 0\n",
+		"postgres":      "#!/bin/sh\necho \"$*\" > \"$OUTPUT.args\"\ncat > \"$OUTPUT.sql\"\n",
+	}
+	for name, content := range stubs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+	}
+
+	// Patroni 在命令后追加 --scope 和 --datadir 参数
+	cmd := exec.Command("sh", "-c", command+" --scope=pg --datadir=/home/postgres/pgdata/pgroot/data")
+	cmd.Env = append(os.Environ(),
+		"PATH="+dir+":"+os.Getenv("PATH"),
+		"OUTPUT="+filepath.Join(dir, "postgres"),
+		"PATRONI_SUPERUSER_USERNAME=postgres",
+		`PATRONI_SUPERUSER_PASSWORD=p'a$s"w%d\n`,
+		"PATRONI_REPLICATION_USERNAME=standby",
+		"PATRONI_REPLICATION_PASSWORD=replication",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run clone bootstrap command failed: %v: %s", err, out)
+	}
+
+	args, _ := ioutil.ReadFile(filepath.Join(dir, "postgres.args"))
+	if expected := "--single -D /home/postgres/pgdata/pgroot/data -c log_statement=none postgres"; strings.TrimSpace(string(args)) != expected {
+		t.Errorf("expected postgres %s, got %s", expected, args)
+	}
+	sql, _ := ioutil.ReadFile(filepath.Join(dir, "postgres.sql"))
+	expected := strings.Join([]string{
+		`DO $do$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $n$postgres$n$) THEN CREATE ROLE "postgres" WITH LOGIN; END IF; END $do$;`,
+		`ALTER ROLE "postgres" WITH SUPERUSER LOGIN PASSWORD $pw$p'a$s"w%d\n$pw$;`,
+		`DO $do$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $n$standby$n$) THEN CREATE ROLE "standby" WITH LOGIN; END IF; END $do$;`,
+		`ALTER ROLE "standby" WITH REPLICATION LOGIN PASSWORD $pw$replication$pw$;`,
+	}, "\n") + "\n"
+	if string(sql) != expected {
+		t.Errorf("expected statements\n%s\ngot\n%s", expected, sql)
+	}
+}
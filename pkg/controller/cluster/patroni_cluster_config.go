@@ -0,0 +1,432 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/utils/owner"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	reasonConfigCreated = "ConfigCreated"
+	reasonConfigUpdated = "ConfigUpdated"
+	reasonConfigApplied = "ConfigApplied"
+	reasonConfigFailed  = "ConfigFailed"
+)
+
+const (
+	patroniConfigKey        = "patroni.yml"
+	patroniConfigMountPath  = "/etc/patroni"
+	patroniConfigAnnotation = "rccp.ruijie.com.cn/config-hash"
+	// 上次通过 PATCH /config 应用的动态配置
+	lastAppliedConfigAnnotation = "rccp.ruijie.com.cn/last-applied-dynamic-config"
+	defaultPgWalMountPath       = "/home/postgres/pgwal"
+	defaultPgWalPath            = defaultPgWalMountPath + "/pg_wal"
+)
+
+// patroniConfigName Patroni 配置文件 ConfigMap 名称，避免与 Patroni 创建的 <scope>-config 对象冲突
+func patroniConfigName(pCluster *clusterv1alpha1.PatroniCluster) string {
+	return fmt.Sprintf("%s-patroni", pCluster.Name)
+}
+
+// hasWALVolume 是否为 WAL 配置了独立数据卷
+func hasWALVolume(pCluster *clusterv1alpha1.PatroniCluster) bool {
+	return pCluster.PatroniClusterSpec.Storage != nil && pCluster.PatroniClusterSpec.Storage.WAL != nil
+}
+
+// generatorPatroniConfig 生成 Patroni 配置文件。
+// 成员以 patroni /etc/patroni/patroni.yml 启动，连接地址、用户和密码等随成员变化的配置
+// 通过 PATRONI_* 环境变量传递，Patroni 加载配置文件后用这些环境变量补充，配置文件中不包含这些字段；
+// bootstrap.dcs 只在集群初始化时写入 DCS，之后的动态配置由 syncDynamicConfig 维护
+func generatorPatroniConfig(pCluster *clusterv1alpha1.PatroniCluster) string {
+
+	initdb := []interface{}{
+		map[string]string{"auth-host": "md5"},
+		map[string]string{"auth-local": "md5"},
+		map[string]string{"encoding": "UTF8"},
+		map[string]string{"locale": "en_US.UTF-8"},
+		"data-checksums",
+	}
+	if hasWALVolume(pCluster) {
+		initdb = append(initdb, map[string]string{"waldir": defaultPgWalPath})
+	}
+
+	bootstrap := map[string]interface{}{
+		"dcs":    dynamicConfig(pCluster),
+		"initdb": initdb,
+	}
+	if method, custom := customBootstrapConfig(pCluster); custom != nil {
+		bootstrap["method"] = method
+		bootstrap[method] = custom
+	}
+
+	config := localPatroniConfig(pCluster)
+	config["bootstrap"] = bootstrap
+
+	// 只包含 map、slice 和基础类型，序列化不会失败
+	data, _ := yaml.Marshal(config)
+	return string(data)
+}
+
+// localPatroniConfig 成员本地配置，变化时需要重启成员
+func localPatroniConfig(pCluster *clusterv1alpha1.PatroniCluster) map[string]interface{} {
+
+	postgresql := map[string]interface{}{}
+	if hasWALVolume(pCluster) {
+		postgresql["basebackup"] = []interface{}{
+			map[string]string{"waldir": defaultPgWalPath},
+		}
+	}
+
+	// 固定 leader 的角色标签值，Patroni 4 默认使用 primary，与服务的选择器保持一致
+	kubernetes := map[string]interface{}{
+		"leader_label_value": leaderLabelValue(pCluster),
+	}
+
+	return map[string]interface{}{
+		"postgresql": postgresql,
+		"kubernetes": kubernetes,
+	}
+}
+
+// leaderLabelValue leader Pod 的 role 标签值
+func leaderLabelValue(pCluster *clusterv1alpha1.PatroniCluster) string {
+	if spec := pCluster.PatroniClusterSpec.Patroni; spec != nil && spec.LeaderLabelValue != "" {
+		return spec.LeaderLabelValue
+	}
+	return patroniMasterRole
+}
+
+// dynamicConfig 保存在 DCS 中的动态配置，集群运行后通过 PATCH /config 应用，不需要重启成员
+func dynamicConfig(pCluster *clusterv1alpha1.PatroniCluster) map[string]interface{} {
+
+	postgresql := map[string]interface{}{
+		"use_pg_rewind": true,
+		"parameters":    postgresqlParameters(pCluster),
+		"pg_hba":        pgHba(pCluster),
+	}
+	// replica 落后过多时从归档中获取 WAL
+	if pCluster.PatroniClusterSpec.BackupStorage != nil {
+		postgresql["recovery_conf"] = map[string]interface{}{
+			"restore_command": walFetchCommand,
+		}
+	}
+	config := map[string]interface{}{
+		"postgresql": postgresql,
+	}
+
+	// 同步复制模式由控制器统一设置，关闭时同样写入以覆盖手工修改
+	switch pCluster.PatroniClusterSpec.SynchronousMode {
+	case clusterv1alpha1.SynchronousOn, clusterv1alpha1.SynchronousStrict:
+		nodeCount := pCluster.PatroniClusterSpec.SynchronousNodeCount
+		if nodeCount < 1 {
+			nodeCount = 1
+		}
+		config["synchronous_mode"] = true
+		config["synchronous_mode_strict"] = pCluster.PatroniClusterSpec.SynchronousMode == clusterv1alpha1.SynchronousStrict
+		config["synchronous_node_count"] = nodeCount
+	default:
+		config["synchronous_mode"] = false
+		config["synchronous_mode_strict"] = false
+	}
+
+	// 删除 standby_cluster 由 promoteStandby 完成
+	if pCluster.PatroniClusterSpec.Standby != nil {
+		config[standbyClusterConfigKey] = standbyConfig(pCluster)
+	}
+
+	if spec := pCluster.PatroniClusterSpec.Patroni; spec != nil {
+		if spec.TTL != nil {
+			config["ttl"] = *spec.TTL
+		}
+		if spec.LoopWait != nil {
+			config["loop_wait"] = *spec.LoopWait
+		}
+		if spec.RetryTimeout != nil {
+			config["retry_timeout"] = *spec.RetryTimeout
+		}
+		if spec.MaximumLagOnFailover != nil {
+			config["maximum_lag_on_failover"] = *spec.MaximumLagOnFailover
+		}
+	}
+
+	return config
+}
+
+// pgHba 用户规则在前，之后是控制器需要的默认规则：
+// 本地连接用于控制器在 Pod 中使用超级用户密码执行 psql，复制连接用于成员之间的流复制
+func pgHba(pCluster *clusterv1alpha1.PatroniCluster) []string {
+
+	var rules []string
+	if pCluster.PatroniClusterSpec.Postgresql != nil {
+		rules = append(rules, pCluster.PatroniClusterSpec.Postgresql.PgHba...)
+	}
+
+	return append(rules,
+		"local all all md5",
+		fmt.Sprintf("host replication %s 0.0.0.0/0 md5", replicationUserName(pCluster)),
+		"host all all 0.0.0.0/0 md5",
+	)
+}
+
+// postgresqlParameters 根据内存限制计算 shared_buffers（25%）和 effective_cache_size（75%），
+// 配置备份存储时开启 WAL 归档，用户配置的参数优先
+func postgresqlParameters(pCluster *clusterv1alpha1.PatroniCluster) map[string]string {
+
+	parameters := map[string]string{}
+
+	resources := pCluster.PatroniClusterSpec.Resources
+	memory, ok := resources.Limits[v1.ResourceMemory]
+	if !ok {
+		memory, ok = resources.Requests[v1.ResourceMemory]
+	}
+	if ok && !memory.IsZero() {
+		kb := memory.Value() / 1024
+		parameters["shared_buffers"] = fmt.Sprintf("%dkB", kb/4)
+		parameters["effective_cache_size"] = fmt.Sprintf("%dkB", kb*3/4)
+	}
+
+	for k, v := range archiveParameters(pCluster) {
+		parameters[k] = v
+	}
+
+	if pCluster.PatroniClusterSpec.Postgresql != nil {
+		for k, v := range pCluster.PatroniClusterSpec.Postgresql.Parameters {
+			parameters[k] = v
+		}
+	}
+
+	return parameters
+}
+
+// patroniConfigHash 本地配置的摘要，动态配置和 bootstrap 配置的变化不需要重启成员
+func patroniConfigHash(pCluster *clusterv1alpha1.PatroniCluster) string {
+	data, _ := yaml.Marshal(localPatroniConfig(pCluster))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// syncConfig 创建或更新集群的 Patroni 配置文件 ConfigMap
+func (c *patroniClusterController) syncConfig(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	ns := pCluster.Namespace
+	name := patroniConfigName(pCluster)
+	config := generatorPatroniConfig(pCluster)
+
+	cm, err := c.kubernetesCli.CoreV1().ConfigMaps(ns).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels: map[string]string{
+					"application":  "patroni",
+					"cluster-name": pCluster.Name,
+				},
+			},
+			Data: map[string]string{
+				patroniConfigKey: config,
+			},
+		}
+		owner.AddOwnerRef(pCluster, cm, clusterv1alpha1.SchemeGroupVersion.WithKind("PatroniCluster"))
+		if _, err := c.kubernetesCli.CoreV1().ConfigMaps(ns).Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+			return errors.Wrapf(err, "create configmap %s/%s failed", ns, name)
+		}
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonConfigCreated, "create patroni config %s", name)
+		return nil
+	}
+
+	if cm.Data[patroniConfigKey] == config {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[patroniConfigKey] = config
+	if _, err := c.kubernetesCli.CoreV1().ConfigMaps(ns).Update(context.Background(), cm, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "update configmap %s/%s failed", ns, name)
+	}
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonConfigUpdated, "update patroni config %s", name)
+	return nil
+}
+
+// syncDynamicConfig 比较 leader 上的动态配置和期望值，存在差异时通过 PATCH /config 应用，
+// 需要重启才能生效的参数由 Patroni 标记为 pending_restart
+func (c *patroniClusterController) syncDynamicConfig(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) error {
+
+	var leader *clusterMember
+	for _, m := range members {
+		if m.leader() && m.healthy() {
+			leader = m
+			break
+		}
+	}
+	if leader == nil {
+		return nil
+	}
+
+	cli := c.patroniCli(leader.pod.Status.PodIP)
+	current, err := cli.Config(context.Background())
+	if err != nil {
+		return errors.Wrapf(err, "get patroni config from %s failed", leader.podName())
+	}
+
+	desired, err := normalizeConfig(dynamicConfig(pCluster))
+	if err != nil {
+		return err
+	}
+
+	cm, err := c.kubernetesCli.CoreV1().ConfigMaps(pCluster.Namespace).Get(context.Background(), patroniConfigName(pCluster), metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "get patroni config %s/%s failed", pCluster.Namespace, patroniConfigName(pCluster))
+	}
+	lastApplied := map[string]interface{}{}
+	if data, ok := cm.Annotations[lastAppliedConfigAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &lastApplied); err != nil {
+			klog.V(4).Infof("parse last applied dynamic config of %s/%s failed: %v", cm.Namespace, cm.Name, err)
+		}
+	}
+
+	patch := configPatch(current, desired, lastApplied)
+	if len(patch) != 0 {
+		if err := cli.PatchConfig(context.Background(), patch); err != nil {
+			return errors.Wrapf(err, "patch patroni config on %s failed", leader.podName())
+		}
+
+		keys := make([]string, 0, len(patch))
+		for k := range patch {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonConfigApplied, "apply patroni dynamic config: %s", strings.Join(keys, ", "))
+	}
+
+	// 记录应用的动态配置，之后从 spec 中删除的配置项据此从 DCS 中删除
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	if cm.Annotations[lastAppliedConfigAnnotation] == string(data) {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[lastAppliedConfigAnnotation] = string(data)
+	if _, err := c.kubernetesCli.CoreV1().ConfigMaps(cm.Namespace).Update(context.Background(), cm, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "update configmap %s/%s failed", cm.Namespace, cm.Name)
+	}
+	return nil
+}
+
+// normalizeConfig 经过一次 json 序列化，使数值类型与 Patroni 返回的配置一致
+func normalizeConfig(config map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// configPatch 生成从当前配置更新到期望配置的合并补丁，
+// postgresql.parameters 由控制器统一管理，期望配置中不存在的参数会被删除；
+// 其他配置项只删除上次应用过、期望配置中已经不存在的项，不影响手工添加的配置
+func configPatch(current, desired, lastApplied map[string]interface{}) map[string]interface{} {
+
+	patch := map[string]interface{}{}
+	for key, want := range desired {
+		if key != "postgresql" {
+			if !reflect.DeepEqual(current[key], want) {
+				patch[key] = want
+			}
+			continue
+		}
+
+		wantPostgresql, _ := want.(map[string]interface{})
+		curPostgresql, _ := current[key].(map[string]interface{})
+		lastPostgresql, _ := lastApplied[key].(map[string]interface{})
+		postgresql := map[string]interface{}{}
+		for k, v := range wantPostgresql {
+			if k == "parameters" {
+				if parameters := parametersPatch(curPostgresql[k], v); len(parameters) != 0 {
+					postgresql[k] = parameters
+				}
+				continue
+			}
+			if !reflect.DeepEqual(curPostgresql[k], v) {
+				postgresql[k] = v
+			}
+		}
+		for k := range removedKeys(curPostgresql, wantPostgresql, lastPostgresql) {
+			postgresql[k] = nil
+		}
+		if len(postgresql) != 0 {
+			patch[key] = postgresql
+		}
+	}
+
+	// standby_cluster 的删除会提升备库集群，由 promoteStandby 完成
+	for key := range removedKeys(current, desired, lastApplied) {
+		if key != standbyClusterConfigKey {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
+
+// removedKeys 上次应用过、期望配置中已经删除并且仍然存在于当前配置中的键
+func removedKeys(current, desired, lastApplied map[string]interface{}) map[string]bool {
+	removed := map[string]bool{}
+	for k := range lastApplied {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		if _, ok := current[k]; ok {
+			removed[k] = true
+		}
+	}
+	return removed
+}
+
+// parametersPatch 参数值统一按字符串比较，Patroni 可能以数值形式返回参数
+func parametersPatch(current, desired interface{}) map[string]interface{} {
+
+	cur, _ := current.(map[string]interface{})
+	want, _ := desired.(map[string]interface{})
+
+	patch := map[string]interface{}{}
+	for k, v := range want {
+		if old, ok := cur[k]; !ok || fmt.Sprint(old) != fmt.Sprint(v) {
+			patch[k] = v
+		}
+	}
+	for k := range cur {
+		if _, ok := want[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigPatch(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		current     map[string]interface{}
+		desired     map[string]interface{}
+		lastApplied map[string]interface{}
+		expected    map[string]interface{}
+	}{
+		{
+			name:     "unchanged",
+			current:  map[string]interface{}{"ttl": 30.0, "loop_wait": 10.0},
+			desired:  map[string]interface{}{"ttl": 30.0},
+			expected: map[string]interface{}{},
+		},
+		{
+			name:        "key removed from spec",
+			current:     map[string]interface{}{"ttl": 60.0, "loop_wait": 10.0},
+			desired:     map[string]interface{}{},
+			lastApplied: map[string]interface{}{"ttl": 60.0},
+			expected:    map[string]interface{}{"ttl": nil},
+		},
+		{
+			name:        "removed key already absent",
+			current:     map[string]interface{}{},
+			desired:     map[string]interface{}{},
+			lastApplied: map[string]interface{}{"ttl": 60.0},
+			expected:    map[string]interface{}{},
+		},
+		{
+			name:        "standby_cluster is removed by promotion",
+			current:     map[string]interface{}{"standby_cluster": map[string]interface{}{"host": "pg-source"}},
+			desired:     map[string]interface{}{},
+			lastApplied: map[string]interface{}{"standby_cluster": map[string]interface{}{"host": "pg-source"}},
+			expected:    map[string]interface{}{},
+		},
+		{
+			name: "postgresql keys and parameters",
+			current: map[string]interface{}{"postgresql": map[string]interface{}{
+				"use_pg_rewind": true,
+				"recovery_conf": map[string]interface{}{"restore_command": "wal-g wal-fetch %f %p"},
+				"parameters":    map[string]interface{}{"max_connections": 100.0, "work_mem": "4MB"},
+			}},
+			desired: map[string]interface{}{"postgresql": map[string]interface{}{
+				"use_pg_rewind": true,
+				"parameters":    map[string]interface{}{"max_connections": "200"},
+			}},
+			lastApplied: map[string]interface{}{"postgresql": map[string]interface{}{
+				"use_pg_rewind": true,
+				"recovery_conf": map[string]interface{}{"restore_command": "wal-g wal-fetch %f %p"},
+			}},
+			expected: map[string]interface{}{"postgresql": map[string]interface{}{
+				"recovery_conf": nil,
+				"parameters":    map[string]interface{}{"max_connections": "200", "work_mem": nil},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch := configPatch(tt.current, tt.desired, tt.lastApplied)
+			if !reflect.DeepEqual(patch, tt.expected) {
+				t.Errorf("expected patch %v, got %v", tt.expected, patch)
+			}
+		})
+	}
+}
@@ -6,13 +6,17 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreInformer "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	coreLister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -25,21 +29,28 @@ import (
 	clusterInformer "pgoperator/pkg/client/informers/externalversions/cluster/v1alpha1"
 	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
 	"pgoperator/pkg/constants"
+	"pgoperator/pkg/simple/client/patroni"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"time"
 )
 
 const (
-	patroniClusterFinalizerStr     = "patroni-cluster-controller"
-	defaultServiceAccountName      = "patroni"
-	defaultClusterRoleBinding      = "patroni-binding"
-	defaultClusterRoleName         = "patroni-ep-access"
-	defaultSuperUserName           = "postgres"
-	defaultSuperUserPassword       = "Ruijie@rccp123"
-	defaultReplicationUserName     = "standby"
-	defaultReplicationUserPassword = "Ruijie@rccp123"
-	defaultPgDataPath              = "/home/postgres/pgdata/pgroot/data"
-	defaultPgPass                  = "/tmp/pgpass"
+	patroniClusterFinalizerStr = "patroni-cluster-controller"
+	defaultServiceAccountName  = "patroni"
+	defaultClusterRoleBinding  = "patroni-binding"
+	defaultClusterRoleName     = "patroni-ep-access"
+	defaultSuperUserName       = "postgres"
+	defaultReplicationUserName = "standby"
+	defaultPgDataPath          = "/home/postgres/pgdata/pgroot/data"
+	defaultPgPass              = "/tmp/pgpass"
+	secretPasswordKey          = "password"
+)
+
+// 事件类型
+const (
+	reasonInitFailed     = "InitFailed"
+	reasonMemberCreated  = "MemberCreated"
+	reasonClusterRunning = "ClusterRunning"
 )
 
 type patroniClusterController struct {
@@ -47,22 +58,32 @@ type patroniClusterController struct {
 	eventRecorder    record.EventRecorder
 
 	kubernetesCli kubernetes.Interface
+	restConfig    *rest.Config
+	execInPod     podExecFunc
 
 	pgOperatorCli pgOperatorCli.Interface
+	statusWriter  statusWriter
+	patroniCli    patroni.ClientFunc
 	clusterLister clusterLister.PatroniClusterLister
 	clusterSynced cache.InformerSynced
 	clusterQueue  workqueue.RateLimitingInterface
 
+	secretLister coreLister.SecretLister
+	secretSynced cache.InformerSynced
+
 	workerCount int
 	retryCount  int
 	period      time.Duration
 	waitPeriod  time.Duration
+	// 集群稳定后通过 RequeueAfter 周期性检查健康状态
+	healthCheckInterval time.Duration
 
 	mrgConfig *options.Config
 }
 
-func NewPatroniClusterController(kubernetesCli kubernetes.Interface, pgOperatorCli pgOperatorCli.Interface,
-	clusterInformer clusterInformer.PatroniClusterInformer, mgrConfig *options.Config) *patroniClusterController {
+func NewPatroniClusterController(kubernetesCli kubernetes.Interface, restConfig *rest.Config, pgOperatorCli pgOperatorCli.Interface,
+	clusterInformer clusterInformer.PatroniClusterInformer, secretInformer coreInformer.SecretInformer,
+	mgrConfig *options.Config) *patroniClusterController {
 
 	broadcaster := record.NewBroadcaster()
 	broadcaster.StartLogging(func(format string, args ...interface{}) {
@@ -75,35 +96,84 @@ func NewPatroniClusterController(kubernetesCli kubernetes.Interface, pgOperatorC
 	r := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "patroni-controller"})
 
 	c := &patroniClusterController{
-		eventBroadcaster: broadcaster,
-		eventRecorder:    r,
-		kubernetesCli:    kubernetesCli,
-		pgOperatorCli:    pgOperatorCli,
-		clusterLister:    clusterInformer.Lister(),
-		clusterSynced:    clusterInformer.Informer().HasSynced,
-		clusterQueue:     workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "patroni-cluster"),
-		workerCount:      5,
-		retryCount:       3,
-		period:           1 * time.Second,
-		waitPeriod:       2 * time.Second,
-		mrgConfig:        mgrConfig,
+		eventBroadcaster:    broadcaster,
+		eventRecorder:       r,
+		kubernetesCli:       kubernetesCli,
+		restConfig:          restConfig,
+		execInPod:           newPodExecFunc(kubernetesCli, restConfig),
+		pgOperatorCli:       pgOperatorCli,
+		statusWriter:        newStatusWriter(pgOperatorCli),
+		patroniCli:          patroni.NewClientFunc(mgrConfig.PatroniOptions),
+		clusterLister:       clusterInformer.Lister(),
+		clusterSynced:       clusterInformer.Informer().HasSynced,
+		clusterQueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "patroni-cluster"),
+		secretLister:        secretInformer.Lister(),
+		secretSynced:        secretInformer.Informer().HasSynced,
+		workerCount:         5,
+		retryCount:          3,
+		period:              1 * time.Second,
+		waitPeriod:          2 * time.Second,
+		healthCheckInterval: mgrConfig.PatroniOptions.HealthCheckInterval,
+		mrgConfig:           mgrConfig,
 	}
 
 	// 安装调谐函数
 	clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			if !clusterChanged(oldObj, newObj) {
+				return
+			}
 			c.enqueueCluster(newObj)
 		},
 		AddFunc:    c.enqueueCluster,
 		DeleteFunc: c.enqueueCluster,
 	})
 
+	// Secret 变化时调谐引用该 Secret 的集群，用于密码轮换
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueSecretClusters(newObj)
+		},
+		AddFunc:    c.enqueueSecretClusters,
+		DeleteFunc: c.enqueueSecretClusters,
+	})
+
 	return c
 }
 
+// clusterChanged 只有状态变化的更新不需要调谐，成员复制延迟等状态字段持续变化，
+// 入队会导致调谐循环；健康检查由 RequeueAfter 周期性触发
+func clusterChanged(oldObj, newObj interface{}) bool {
+
+	oldCluster, ok := oldObj.(*clusterv1alpha1.PatroniCluster)
+	if !ok {
+		return true
+	}
+	newCluster, ok := newObj.(*clusterv1alpha1.PatroniCluster)
+	if !ok {
+		return true
+	}
+
+	return oldCluster.Generation != newCluster.Generation ||
+		!equality.Semantic.DeepEqual(oldCluster.DeletionTimestamp, newCluster.DeletionTimestamp) ||
+		!equality.Semantic.DeepEqual(oldCluster.Finalizers, newCluster.Finalizers) ||
+		!equality.Semantic.DeepEqual(oldCluster.Annotations, newCluster.Annotations) ||
+		!equality.Semantic.DeepEqual(oldCluster.PatroniClusterSpec, newCluster.PatroniClusterSpec)
+}
+
 func (c *patroniClusterController) enqueueCluster(obj interface{}) {
 
-	clusterObj := obj.(*clusterv1alpha1.PatroniCluster)
+	clusterObj, ok := obj.(*clusterv1alpha1.PatroniCluster)
+	if !ok {
+		// 删除事件可能携带 informer 错过删除时留下的 tombstone
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if clusterObj, ok = tombstone.Obj.(*clusterv1alpha1.PatroniCluster); !ok {
+			return
+		}
+	}
 	key, err := cache.MetaNamespaceKeyFunc(clusterObj)
 	if err != nil {
 		utilruntime.HandleError(errors.Errorf("get patroni cluster key %s failed", clusterObj.Name))
@@ -168,7 +238,7 @@ func (c *patroniClusterController) Run(ctx context.Context) error {
 	}()
 
 	klog.V(0).Infof("starting patroni cluster controller")
-	if !cache.WaitForCacheSync(ctx.Done(), c.clusterSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), c.clusterSynced, c.secretSynced) {
 		return errors.New("failed to wait for cached to sync")
 	}
 
@@ -198,15 +268,35 @@ func (c *patroniClusterController) handleCluster(key string) (ctrl.Result, error
 	pCluster, err := c.clusterLister.PatroniClusters(ns).Get(name)
 
 	if err != nil {
+		// 对象已经被删除
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
 		klog.Error(errors.Wrapf(err, "Failed to get patroni-cluster object on cache %s/%s", ns, name))
 		return ctrl.Result{}, err
 	}
 
+	// 避免修改 informer 缓存中的对象
+	pCluster = pCluster.DeepCopy()
+
 	pClusterFinalizer := sets.NewString(pCluster.ObjectMeta.Finalizers...)
 
-	if pClusterFinalizer.Has(patroniClusterFinalizerStr) && !pCluster.ObjectMeta.DeletionTimestamp.IsZero() {
+	if !pCluster.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !pClusterFinalizer.Has(patroniClusterFinalizerStr) {
+			return ctrl.Result{}, nil
+		}
+
+		done, err := c.teardownCluster(pCluster)
+		if err != nil {
+			klog.Error(errors.Wrapf(err, "teardown patroni cluster %s/%s failed", ns, name))
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonTeardownFailed, "teardown patroni cluster failed: %v", err)
+			return ctrl.Result{}, err
+		}
 
-		// TODO: 删除逻辑
+		// 等待成员退出后继续清理
+		if !done {
+			return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+		}
 
 		// 执行完成删除逻辑后移除Finlizer，CRD正式被删除
 		pClusterFinalizer.Delete(patroniClusterFinalizerStr)
@@ -221,20 +311,116 @@ func (c *patroniClusterController) handleCluster(key string) (ctrl.Result, error
 	// ADD 控制：新创建的Obj没有对应 Finalizer
 	if !pClusterFinalizer.Has(patroniClusterFinalizerStr) {
 		pCluster.ObjectMeta.Finalizers = append(pCluster.ObjectMeta.Finalizers, patroniClusterFinalizerStr)
-		pCluster.PatroniClusterStatus.Status = clusterv1alpha1.ClusterInit
-		if _, err := c.pgOperatorCli.RccpV1alpha1().PatroniClusters(ns).Update(context.Background(), pCluster, metav1.UpdateOptions{}); err != nil {
+		pCluster, err = c.pgOperatorCli.RccpV1alpha1().PatroniClusters(ns).Update(context.Background(), pCluster, metav1.UpdateOptions{})
+		if err != nil {
 			klog.Error(errors.Wrap(err, "Add finalizer hook failed..."))
 			return ctrl.Result{}, err
 		}
+	}
 
-		// TODO: 创建集群逻辑
+	// 用户密码通过 Secret 引用，未指定时自动生成，Secret 不可用时不创建或更新成员
+	pCluster, err = c.ensureCredentials(pCluster)
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "generate patroni cluster %s/%s credentials failed", ns, name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidCredential, err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := c.checkCredentials(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "check patroni cluster %s/%s credentials failed", ns, name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidCredential, err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := c.checkMembers(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "check patroni cluster %s/%s members failed", ns, name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidMember, err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := c.checkBackupStorage(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "check patroni cluster %s/%s backup storage failed", ns, name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidBackupStorage, err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := c.checkStandby(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "check patroni cluster %s/%s standby failed", ns, name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidStandby, err.Error())
+		return ctrl.Result{}, err
+	}
 
-		return ctrl.Result{}, nil
+	// 创建集群逻辑：集群所有成员 Ready 之前一直处于 Initialized 状态
+	if pCluster.PatroniClusterStatus.Status == "" || pCluster.PatroniClusterStatus.Status == clusterv1alpha1.ClusterInit {
+		pCluster, err = c.resolveBootstrap(pCluster)
+		if err != nil {
+			klog.Error(errors.Wrapf(err, "resolve patroni cluster %s/%s bootstrap failed", ns, name))
+			c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidBootstrap, err.Error())
+			return ctrl.Result{}, err
+		}
+		return c.createCluster(pCluster)
 	}
 
-	//TODO: Update 逻辑，幂等逻辑主要功能包括：滚动更新、健康检查
+	// Secret 中的密码变化后先应用到数据库，再由滚动更新重启成员
+	pCluster, wait, err := c.rotateCredentials(pCluster)
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "rotate patroni cluster %s/%s credentials failed", ns, name))
+		return ctrl.Result{}, err
+	}
+	if wait {
+		return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+	}
 
-	return ctrl.Result{}, nil
+	// Update 逻辑：幂等的滚动更新和周期性健康检查
+	return c.updateCluster(pCluster)
+}
+
+func (c *patroniClusterController) createCluster(pCluster *clusterv1alpha1.PatroniCluster) (ctrl.Result, error) {
+
+	oldStatus := pCluster.PatroniClusterStatus.DeepCopy()
+	pCluster.PatroniClusterStatus.Status = clusterv1alpha1.ClusterInit
+
+	if err := c.initCredentialStatus(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "init patroni cluster %s/%s credential status failed", pCluster.Namespace, pCluster.Name))
+		return ctrl.Result{}, err
+	}
+
+	if err := c.initCluster(pCluster); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonInitFailed, "init patroni cluster failed: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	members, err := c.listMembers(pCluster)
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "check patroni cluster %s/%s members failed", pCluster.Namespace, pCluster.Name))
+		return ctrl.Result{}, err
+	}
+
+	ready := true
+	for _, m := range members {
+		if m.pod == nil || !isPodReady(m.pod) {
+			ready = false
+			break
+		}
+	}
+
+	c.syncStandbyStatus(pCluster, members)
+	if ready {
+		pCluster.PatroniClusterStatus.Status = clusterv1alpha1.ClusterRunning
+		c.syncClusterStatus(pCluster, members, "", nil)
+	} else {
+		c.syncClusterStatus(pCluster, members, conditionReasonInitializing, nil)
+	}
+
+	if err := c.updateClusterStatus(pCluster, oldStatus); err != nil {
+		klog.Error(errors.Wrap(err, "Update patroni cluster status failed..."))
+		return ctrl.Result{}, err
+	}
+
+	// 成员 Pod 尚未全部 Ready，等待后重新入队
+	if !ready {
+		klog.V(4).Infof("patroni cluster %s/%s members not ready, waiting...", pCluster.Namespace, pCluster.Name)
+		return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+	}
+
+	c.eventRecorder.Event(pCluster, v1.EventTypeNormal, reasonClusterRunning, "all patroni cluster members are ready")
+	return ctrl.Result{RequeueAfter: c.healthCheckInterval}, nil
 }
 
 func (c *patroniClusterController) initCluster(pCluster *clusterv1alpha1.PatroniCluster) error {
@@ -244,9 +430,31 @@ func (c *patroniClusterController) initCluster(pCluster *clusterv1alpha1.Patroni
 		return err
 	}
 
+	if err := c.syncServices(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "create services for patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name))
+		return err
+	}
+
+	if err := c.syncConfig(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "sync patroni config for cluster %s/%s failed", pCluster.Namespace, pCluster.Name))
+		return err
+	}
+
+	nodes := pCluster.PatroniClusterSpec.NodeList
+	pending, err := c.bootstrapPending(pCluster)
+	if err != nil {
+		return errors.Wrapf(err, "check bootstrap of patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+	if pending {
+		nodes = nodes[:1]
+	}
+
+	// 集群运行后新加入的成员逐个创建
+	scaling := pCluster.PatroniClusterStatus.Status == clusterv1alpha1.ClusterRunning
+
 	ns := pCluster.Namespace
 	pClusterName := pCluster.Name
-	for _, n := range pCluster.PatroniClusterSpec.NodeList {
+	for _, n := range nodes {
 		replName := fmt.Sprintf("%s-%s", pClusterName, n)
 		_, err := c.kubernetesCli.AppsV1().StatefulSets(ns).Get(context.Background(), replName, metav1.GetOptions{})
 		if err != nil {
@@ -255,6 +463,13 @@ func (c *patroniClusterController) initCluster(pCluster *clusterv1alpha1.Patroni
 				return err
 			}
 
+			if scaling {
+				ready, err := c.scaleOutReady(pCluster)
+				if err != nil || !ready {
+					return err
+				}
+			}
+
 			stsTpl := generatorStatefulset(n, pCluster)
 
 			_, err = c.kubernetesCli.AppsV1().StatefulSets(ns).Create(context.Background(), &stsTpl, metav1.CreateOptions{})
@@ -262,12 +477,22 @@ func (c *patroniClusterController) initCluster(pCluster *clusterv1alpha1.Patroni
 				klog.Error(errors.Wrapf(err, "init patroni cluster replicas statefelset %s/%s failed, unable create statefulset", ns, replName))
 				return err
 			}
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonMemberCreated, "create patroni member statefulset %s", replName)
 		}
 	}
 
 	return nil
 }
 
+func isPodReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
 func (c *patroniClusterController) grantPermission(namespace string) error {
 
 	// 指定命名空间中创建 serviceaccount
@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"testing"
+)
+
+// TestClusterChanged 只有状态变化的更新不入队
+func TestClusterChanged(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		update  func(pCluster *clusterv1alpha1.PatroniCluster)
+		changed bool
+	}{
+		{
+			name: "member lag",
+			update: func(pCluster *clusterv1alpha1.PatroniCluster) {
+				lag := int64(4096)
+				pCluster.ResourceVersion = "2"
+				pCluster.PatroniClusterStatus.Members = []clusterv1alpha1.MemberStatus{{Name: "pg-b-0", Lag: &lag}}
+			},
+		},
+		{
+			name: "spec",
+			update: func(pCluster *clusterv1alpha1.PatroniCluster) {
+				pCluster.Generation = 2
+				pCluster.PatroniClusterSpec.NodeList = append(pCluster.PatroniClusterSpec.NodeList, "c")
+			},
+			changed: true,
+		},
+		{
+			name: "annotation",
+			update: func(pCluster *clusterv1alpha1.PatroniCluster) {
+				pCluster.Annotations = map[string]string{clusterv1alpha1.RotateCredentialsAnnotation: "all"}
+			},
+			changed: true,
+		},
+		{
+			name: "deletion",
+			update: func(pCluster *clusterv1alpha1.PatroniCluster) {
+				now := metav1.Now()
+				pCluster.DeletionTimestamp = &now
+			},
+			changed: true,
+		},
+		{
+			name: "finalizer",
+			update: func(pCluster *clusterv1alpha1.PatroniCluster) {
+				pCluster.Finalizers = nil
+			},
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCluster := newTestCluster("a", "b")
+			oldCluster.Generation = 1
+			oldCluster.Finalizers = []string{patroniClusterFinalizerStr}
+			newCluster := oldCluster.DeepCopy()
+			tt.update(newCluster)
+			if changed := clusterChanged(oldCluster, newCluster); changed != tt.changed {
+				t.Errorf("expected changed %v, got %v", tt.changed, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"math/big"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/utils/owner"
+	"strings"
+)
+
+// 事件类型
+const (
+	reasonInvalidCredential   = "InvalidCredential"
+	reasonCredentialGenerated = "CredentialGenerated"
+	reasonCredentialRotated   = "CredentialRotated"
+	reasonRotationFailed      = "RotationFailed"
+	reasonRotationSkipped     = "RotationSkipped"
+	reasonRotationDeferred    = "RotationDeferred"
+)
+
+const (
+	secretUsernameKey       = "username"
+	generatedPasswordLength = 24
+	passwordCharset         = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+func superUserName(pCluster *clusterv1alpha1.PatroniCluster) string {
+	if pCluster.PatroniClusterSpec.SuperUserName == "" {
+		return defaultSuperUserName
+	}
+	return pCluster.PatroniClusterSpec.SuperUserName
+}
+
+func replicationUserName(pCluster *clusterv1alpha1.PatroniCluster) string {
+	if pCluster.PatroniClusterSpec.ReplicationUserName == "" {
+		return defaultReplicationUserName
+	}
+	return pCluster.PatroniClusterSpec.ReplicationUserName
+}
+
+// checkCredentials 检查集群引用的用户 Secret 是否存在并包含密码
+func (c *patroniClusterController) checkCredentials(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	secrets := map[string]string{
+		"superUserSecretName":       pCluster.PatroniClusterSpec.SuperUserSecretName,
+		"replicationUserSecretName": pCluster.PatroniClusterSpec.ReplicationUserSecretName,
+	}
+
+	for field, name := range secrets {
+		secret, err := c.kubernetesCli.CoreV1().Secrets(pCluster.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return fmt.Errorf("secret %s/%s referenced by spec.%s not found", pCluster.Namespace, name, field)
+			}
+			return errors.Wrapf(err, "get secret %s/%s failed", pCluster.Namespace, name)
+		}
+
+		if len(secret.Data[secretPasswordKey]) == 0 {
+			return fmt.Errorf("secret %s/%s has no %q key", pCluster.Namespace, name, secretPasswordKey)
+		}
+	}
+
+	return nil
+}
+
+// ensureCredentials 为没有引用 Secret 的用户生成随机密码，Secret 由集群持有并随集群回收，
+// 生成的 Secret 名称回写到 spec 中
+func (c *patroniClusterController) ensureCredentials(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+
+	spec := pCluster.PatroniClusterSpec
+	patch := map[string]interface{}{}
+
+	if spec.SuperUserSecretName == "" {
+		name := fmt.Sprintf("%s-superuser", pCluster.Name)
+		if err := c.ensureCredentialSecret(pCluster, name, superUserName(pCluster)); err != nil {
+			return pCluster, err
+		}
+		patch["superUserSecretName"] = name
+	}
+
+	if spec.ReplicationUserSecretName == "" {
+		name := fmt.Sprintf("%s-replication", pCluster.Name)
+		if err := c.ensureCredentialSecret(pCluster, name, replicationUserName(pCluster)); err != nil {
+			return pCluster, err
+		}
+		patch["replicationUserSecretName"] = name
+	}
+
+	if len(patch) == 0 {
+		return pCluster, nil
+	}
+
+	updated, err := c.patchSpec(pCluster, patch)
+	if err != nil {
+		return pCluster, err
+	}
+	return updated, nil
+}
+
+// ensureCredentialSecret 创建保存随机密码的 Secret，已经由集群创建的 Secret 直接复用
+func (c *patroniClusterController) ensureCredentialSecret(pCluster *clusterv1alpha1.PatroniCluster, name, username string) error {
+
+	ns := pCluster.Namespace
+	secret, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), name, metav1.GetOptions{})
+	if err == nil {
+		if !owner.HasOwnerRef(pCluster, secret) {
+			return fmt.Errorf("secret %s/%s already exists and is not owned by patroni cluster %s", ns, name, pCluster.Name)
+		}
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	password, err := generatePassword(generatedPasswordLength)
+	if err != nil {
+		return errors.Wrap(err, "generate password failed")
+	}
+
+	secret = &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				"application":  "patroni",
+				"cluster-name": pCluster.Name,
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			secretUsernameKey: []byte(username),
+			secretPasswordKey: []byte(password),
+		},
+	}
+	owner.AddOwnerRef(pCluster, secret, clusterv1alpha1.SchemeGroupVersion.WithKind("PatroniCluster"))
+
+	if _, err := c.kubernetesCli.CoreV1().Secrets(ns).Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "create secret %s/%s failed", ns, name)
+	}
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonCredentialGenerated, "generate credential secret %s for user %s", name, username)
+
+	return nil
+}
+
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordCharset)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordCharset[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// enqueueSecretClusters 将引用该 Secret 的集群加入队列
+func (c *patroniClusterController) enqueueSecretClusters(obj interface{}) {
+
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if secret, ok = tombstone.Obj.(*v1.Secret); !ok {
+			return
+		}
+	}
+
+	clusters, err := c.clusterLister.PatroniClusters(secret.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Error(errors.Wrapf(err, "list patroni clusters in namespace %s failed", secret.Namespace))
+		return
+	}
+
+	for _, pCluster := range clusters {
+		spec := pCluster.PatroniClusterSpec
+		if spec.SuperUserSecretName == secret.Name || spec.ReplicationUserSecretName == secret.Name {
+			c.enqueueCluster(pCluster)
+		}
+	}
+}
+
+// credentialHash 计算密码摘要，加入 Secret UID 避免状态中出现可以直接比对的密码摘要
+func credentialHash(secret *v1.Secret) string {
+	sum := sha256.Sum256(append([]byte(secret.UID), secret.Data[secretPasswordKey]...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *patroniClusterController) credentialSecrets(pCluster *clusterv1alpha1.PatroniCluster) (*v1.Secret, *v1.Secret, error) {
+
+	ns := pCluster.Namespace
+	superUser, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), pCluster.PatroniClusterSpec.SuperUserSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replicationUser, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), pCluster.PatroniClusterSpec.ReplicationUserSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return superUser, replicationUser, nil
+}
+
+// initCredentialStatus 记录集群初始化时使用的密码摘要，成员 Pod 模板中会携带该摘要
+func (c *patroniClusterController) initCredentialStatus(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	if pCluster.PatroniClusterStatus.Credentials != nil {
+		return nil
+	}
+
+	superUser, replicationUser, err := c.credentialSecrets(pCluster)
+	if err != nil {
+		return err
+	}
+
+	pCluster.PatroniClusterStatus.Credentials = &clusterv1alpha1.CredentialStatus{
+		SuperUserHash:       credentialHash(superUser),
+		ReplicationUserHash: credentialHash(replicationUser),
+	}
+	return nil
+}
+
+// rotateCredentials 处理轮换注解，并将 Secret 中变化的密码通过 ALTER ROLE 应用到 leader，
+// 之后更新成员的 pgpass；状态中的摘要变化后 Pod 模板随之变化，由滚动更新重启成员加载新密码。
+// 返回 true 表示需要等待 leader 就绪
+func (c *patroniClusterController) rotateCredentials(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, bool, error) {
+
+	ns := pCluster.Namespace
+
+	if users, ok := pCluster.Annotations[clusterv1alpha1.RotateCredentialsAnnotation]; ok {
+		if err := c.regenerateCredentials(pCluster, users); err != nil {
+			return pCluster, false, err
+		}
+		delete(pCluster.Annotations, clusterv1alpha1.RotateCredentialsAnnotation)
+		updated, err := c.pgOperatorCli.RccpV1alpha1().PatroniClusters(ns).Update(context.Background(), pCluster, metav1.UpdateOptions{})
+		if err != nil {
+			return pCluster, false, err
+		}
+		pCluster = updated
+	}
+
+	// 升级前创建的集群没有记录摘要，直接记录当前值
+	if pCluster.PatroniClusterStatus.Credentials == nil {
+		if err := c.initCredentialStatus(pCluster); err != nil {
+			return pCluster, false, err
+		}
+		updated, err := c.updateStatus(pCluster)
+		if err != nil {
+			return pCluster, false, err
+		}
+		return updated, false, nil
+	}
+
+	superUser, replicationUser, err := c.credentialSecrets(pCluster)
+	if err != nil {
+		return pCluster, false, err
+	}
+
+	applied := pCluster.PatroniClusterStatus.Credentials
+	superUserHash := credentialHash(superUser)
+	replicationUserHash := credentialHash(replicationUser)
+	if applied.SuperUserHash == superUserHash && applied.ReplicationUserHash == replicationUserHash {
+		if !applied.RotationPending {
+			return pCluster, false, nil
+		}
+		// Secret 恢复为已经应用的密码
+		pCluster.PatroniClusterStatus.Credentials.RotationPending = false
+		updated, err := c.updateStatus(pCluster)
+		if err != nil {
+			return pCluster, false, err
+		}
+		return updated, false, nil
+	}
+
+	// 备库集群只读，用户密码从源集群复制，提升为主集群之后再修改，期间不记录新的摘要
+	if pCluster.PatroniClusterStatus.Standby != nil {
+		if applied.RotationPending {
+			return pCluster, false, nil
+		}
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonRotationDeferred,
+			"standby cluster is read-only, credentials will be rotated after promotion, change passwords on the source cluster")
+		pCluster.PatroniClusterStatus.Credentials.RotationPending = true
+		updated, err := c.updateStatus(pCluster)
+		if err != nil {
+			return pCluster, false, err
+		}
+		return updated, false, nil
+	}
+
+	members, err := c.listMembers(pCluster)
+	if err != nil {
+		return pCluster, false, err
+	}
+
+	var leader *clusterMember
+	for _, m := range members {
+		if m.leader() && m.ready() {
+			leader = m
+			break
+		}
+	}
+	if leader == nil {
+		klog.V(4).Infof("patroni cluster %s/%s has no ready leader, waiting to rotate credentials", ns, pCluster.Name)
+		return pCluster, true, nil
+	}
+	// 所有成员的 pgpass 都更新之后才记录摘要，成员未运行时等待，避免遗漏成员
+	for _, m := range members {
+		if m.pod == nil || m.pod.Status.Phase != v1.PodRunning {
+			klog.V(4).Infof("patroni member %s/%s is not running, waiting to rotate credentials", ns, m.podName())
+			return pCluster, true, nil
+		}
+	}
+
+	// 1. 在 leader 上修改密码，成员重启之前环境变量中仍是启动时的超级用户密码，
+	// 上次轮换可能已经修改了密码但没有完成，因此先使用新密码连接，失败时再使用启动时的密码
+	statements := []string{"SET log_statement = 'none';"}
+	if applied.SuperUserHash != superUserHash {
+		statements = append(statements, alterRoleStatement(superUserName(pCluster), string(superUser.Data[secretPasswordKey])))
+	}
+	if applied.ReplicationUserHash != replicationUserHash {
+		statements = append(statements, alterRoleStatement(replicationUserName(pCluster), string(replicationUser.Data[secretPasswordKey])))
+	}
+
+	script := alterRoleScript(string(superUser.Data[secretPasswordKey]), statements)
+	if _, err := c.execInPod(leader.pod, []string{"sh", "-s"}, strings.NewReader(script)); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonRotationFailed, "alter role on leader %s failed: %v", leader.podName(), err)
+		return pCluster, false, err
+	}
+
+	// 2. 更新成员的 pgpass，replica 重连 leader 时使用新的复制用户密码
+	pgpass := pgpassEntry(replicationUserName(pCluster), string(replicationUser.Data[secretPasswordKey])) +
+		pgpassEntry(superUserName(pCluster), string(superUser.Data[secretPasswordKey]))
+	command := []string{"sh", "-c", fmt.Sprintf("umask 077 && cat > %[1]s.tmp && mv %[1]s.tmp %[1]s", defaultPgPass)}
+	// 失败时不记录摘要，下次调谐重新执行，ALTER ROLE 和 pgpass 的修改都是幂等的
+	for _, m := range members {
+		if _, err := c.execInPod(m.pod, command, strings.NewReader(pgpass)); err != nil {
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonRotationFailed, "update pgpass on %s failed: %v", m.podName(), err)
+			return pCluster, false, err
+		}
+	}
+
+	// 3. 记录新的摘要，触发成员滚动重启
+	now := metav1.Now()
+	pCluster.PatroniClusterStatus.Credentials = &clusterv1alpha1.CredentialStatus{
+		SuperUserHash:       superUserHash,
+		ReplicationUserHash: replicationUserHash,
+		LastRotationTime:    &now,
+	}
+	updated, err := c.updateStatus(pCluster)
+	if err != nil {
+		return pCluster, false, err
+	}
+	c.eventRecorder.Event(pCluster, v1.EventTypeNormal, reasonCredentialRotated, "credentials applied on leader, rolling restart members")
+
+	return updated, false, nil
+}
+
+// regenerateCredentials 为集群持有的 Secret 重新生成密码，用户自行维护的 Secret 需要直接修改其内容
+func (c *patroniClusterController) regenerateCredentials(pCluster *clusterv1alpha1.PatroniCluster, users string) error {
+
+	var names []string
+	switch users {
+	case "superuser":
+		names = []string{pCluster.PatroniClusterSpec.SuperUserSecretName}
+	case "replication":
+		names = []string{pCluster.PatroniClusterSpec.ReplicationUserSecretName}
+	default:
+		names = []string{pCluster.PatroniClusterSpec.SuperUserSecretName, pCluster.PatroniClusterSpec.ReplicationUserSecretName}
+	}
+
+	ns := pCluster.Namespace
+	for _, name := range names {
+		secret, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "get secret %s/%s failed", ns, name)
+		}
+
+		if !owner.HasOwnerRef(pCluster, secret) {
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonRotationSkipped, "secret %s is not managed by controller, update its content to rotate", name)
+			continue
+		}
+
+		password, err := generatePassword(generatedPasswordLength)
+		if err != nil {
+			return errors.Wrap(err, "generate password failed")
+		}
+		secret.Data[secretPasswordKey] = []byte(password)
+		if _, err := c.kubernetesCli.CoreV1().Secrets(ns).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "update secret %s/%s failed", ns, name)
+		}
+	}
+
+	return nil
+}
+
+// alterRoleScript 生成在 leader 上执行 ALTER ROLE 的脚本，脚本通过 stdin 传入，密码不出现在进程参数中
+func alterRoleScript(superUserPassword string, statements []string) string {
+	return fmt.Sprintf(`export PGPASSWORD=%s
+psql -U "$PATRONI_SUPERUSER_USERNAME" -d postgres -q -c 'SELECT 1' >/dev/null 2>&1 </dev/null || export PGPASSWORD="$PATRONI_SUPERUSER_PASSWORD"
+psql -U "$PATRONI_SUPERUSER_USERNAME" -d postgres -q -v ON_ERROR_STOP=1 <<'PATRONI_ALTER_ROLE'
+%s
+PATRONI_ALTER_ROLE
+`, shellQuote(superUserPassword), strings.Join(statements, "\n"))
+}
+
+// shellQuote 使用单引号转义 shell 参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func alterRoleStatement(user, password string) string {
+	return fmt.Sprintf("ALTER ROLE \"%s\" WITH PASSWORD '%s';",
+		strings.ReplaceAll(user, `"`, `""`), strings.ReplaceAll(password, "'", "''"))
+}
+
+// pgpassEntry 生成 pgpass 记录，用户名和密码中的 : 和 \ 需要转义
+func pgpassEntry(user, password string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+	return fmt.Sprintf("*:*:*:%s:%s\n", escape.Replace(user), escape.Replace(password))
+}
@@ -0,0 +1,144 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"regexp"
+	"testing"
+)
+
+// fakePostgres 成员容器中的 psql 替身，记录数据库中超级用户的实际密码
+type fakePostgres struct {
+	// 数据库中超级用户的密码
+	password string
+	// 成员启动时环境变量中的超级用户密码
+	envPassword string
+	// 每次执行 ALTER ROLE 时认证使用的密码
+	logins []string
+	// 更新 pgpass 失败的成员 Pod
+	failPgPass string
+}
+
+var (
+	fakeFirstPassword = regexp.MustCompile(`^export PGPASSWORD='([^']*)'`)
+	fakeFallbackLogin = regexp.MustCompile(`\|\| export PGPASSWORD="\$PATRONI_SUPERUSER_PASSWORD"`)
+	fakeAlterSuper    = regexp.MustCompile(`ALTER ROLE "postgres" WITH PASSWORD '([^']*)';`)
+)
+
+func (f *fakePostgres) exec(pod *v1.Pod, command []string, stdin io.Reader) (string, error) {
+
+	data, _ := ioutil.ReadAll(stdin)
+	switch {
+	case len(command) == 2 && command[0] == "sh" && command[1] == "-s":
+		script := string(data)
+		login := fakeFirstPassword.FindStringSubmatch(script)[1]
+		if login != f.password && fakeFallbackLogin.MatchString(script) {
+			login = f.envPassword
+		}
+		f.logins = append(f.logins, login)
+		if login != f.password {
+			return "", fmt.Errorf(`psql: FATAL: password authentication failed for user "postgres"`)
+		}
+		if m := fakeAlterSuper.FindStringSubmatch(script); m != nil {
+			f.password = m[1]
+		}
+		return "", nil
+	case len(command) == 3 && command[0] == "sh" && command[1] == "-c":
+		if pod.Name == f.failPgPass {
+			return "", fmt.Errorf("exec in pod %s failed", pod.Name)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unexpected command %v", command)
+}
+
+func newCredentialSecret(name, password string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Data:       map[string][]byte{secretPasswordKey: []byte(password)},
+	}
+}
+
+// TestRotateCredentialsRetry ALTER ROLE 成功之后更新 pgpass 失败，重试时使用新密码连接并完成轮换
+func TestRotateCredentialsRetry(t *testing.T) {
+
+	oldSuperUser := newCredentialSecret("pg-superuser", "old")
+	replicationUser := newCredentialSecret("pg-replication", "replication")
+	superUser := newCredentialSecret("pg-superuser", "new")
+
+	kubernetesCli := fake.NewSimpleClientset(superUser, replicationUser)
+	for _, name := range []string{"a", "b"} {
+		role := patroniReplicaRole
+		if name == "a" {
+			role = "master"
+		}
+		kubernetesCli.AppsV1().StatefulSets("default").Create(context.Background(), &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-" + name, Namespace: "default"},
+		}, metav1.CreateOptions{})
+		kubernetesCli.CoreV1().Pods("default").Create(context.Background(), &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-" + name + "-0", Namespace: "default", Labels: map[string]string{patroniRoleLabel: role}},
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}, metav1.CreateOptions{})
+	}
+
+	client := newFakePgOperatorCli()
+	pCluster := newTestCluster("a", "b")
+	pCluster.PatroniClusterSpec.SuperUserSecretName = superUser.Name
+	pCluster.PatroniClusterSpec.ReplicationUserSecretName = replicationUser.Name
+	pCluster, err := client.RccpV1alpha1().PatroniClusters("default").Create(context.Background(), pCluster, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create patroni cluster failed: %v", err)
+	}
+
+	postgres := &fakePostgres{password: "old", envPassword: "old", failPgPass: "pg-b-0"}
+	c := &patroniClusterController{
+		kubernetesCli: kubernetesCli,
+		pgOperatorCli: client,
+		statusWriter:  &fakeStatusWriter{client: client},
+		eventRecorder: record.NewFakeRecorder(100),
+		execInPod:     postgres.exec,
+	}
+
+	pCluster.PatroniClusterStatus.Credentials = &clusterv1alpha1.CredentialStatus{
+		SuperUserHash:       credentialHash(oldSuperUser),
+		ReplicationUserHash: credentialHash(replicationUser),
+	}
+	if pCluster, err = c.updateStatus(pCluster); err != nil {
+		t.Fatalf("update patroni cluster status failed: %v", err)
+	}
+
+	// 密码已经修改，pgpass 没有全部更新，不记录摘要
+	pCluster, _, err = c.rotateCredentials(pCluster)
+	if err == nil {
+		t.Fatalf("expected update pgpass failed")
+	}
+	if postgres.password != "new" || pCluster.PatroniClusterStatus.Credentials.SuperUserHash != credentialHash(oldSuperUser) {
+		t.Fatalf("expected password altered without recording hash, got password %s status %+v", postgres.password, pCluster.PatroniClusterStatus.Credentials)
+	}
+
+	// 成员没有重启，环境变量中仍是旧密码
+	postgres.failPgPass = ""
+	pCluster, _, err = c.rotateCredentials(pCluster)
+	if err != nil {
+		t.Fatalf("rotate credentials failed: %v", err)
+	}
+	if fmt.Sprint(postgres.logins) != "[old new]" {
+		t.Errorf("expected retry authenticated with new password, got logins %v", postgres.logins)
+	}
+	credentials := pCluster.PatroniClusterStatus.Credentials
+	if credentials.SuperUserHash != credentialHash(superUser) || credentials.LastRotationTime == nil {
+		t.Errorf("expected rotated credentials recorded, got %+v", credentials)
+	}
+}
@@ -0,0 +1,295 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+)
+
+// 事件类型
+const (
+	reasonMembersDeleted    = "MembersDeleted"
+	reasonDCSDeleted        = "DCSDeleted"
+	reasonVolumesDeleted    = "VolumesDeleted"
+	reasonVolumesRetained   = "VolumesRetained"
+	reasonPermissionRevoked = "PermissionRevoked"
+	reasonTeardownFailed    = "TeardownFailed"
+)
+
+// teardownCluster 清理控制器为集群创建的所有资源，全部清理完成后返回 true
+func (c *patroniClusterController) teardownCluster(pCluster *clusterv1alpha1.PatroniCluster) (bool, error) {
+
+	ns := pCluster.Namespace
+	clusterSelector := labels.SelectorFromSet(map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}).String()
+
+	// 1. 删除集群成员 statefulset
+	stsList, err := c.kubernetesCli.AppsV1().StatefulSets(ns).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return false, err
+	}
+	for _, sts := range stsList.Items {
+		if err := c.kubernetesCli.AppsV1().StatefulSets(ns).Delete(context.Background(), sts.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "delete statefulset %s/%s failed", ns, sts.Name)
+		}
+	}
+	if len(stsList.Items) != 0 {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonMembersDeleted, "delete %d patroni member statefulsets", len(stsList.Items))
+	}
+
+	// 2. 等待成员 Pod 退出，避免 Patroni 重新写入 DCS 对象
+	pods, err := c.kubernetesCli.CoreV1().Pods(ns).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return false, err
+	}
+	if len(pods.Items) != 0 {
+		klog.V(4).Infof("waiting for %d patroni pods of %s/%s to terminate", len(pods.Items), ns, pCluster.Name)
+		return false, nil
+	}
+
+	// 3. 删除 Patroni 在 kubernetes 中维护的 DCS 对象（endpoints、configmaps、services）
+	deleted, err := c.deleteDCSObjects(ns, clusterSelector)
+	if err != nil {
+		return false, err
+	}
+	if deleted != 0 {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonDCSDeleted, "delete %d patroni dcs objects", deleted)
+	}
+
+	// 4. 根据回收策略处理成员数据卷
+	ids, err := c.clusterVolumeIds(pCluster, stsList.Items)
+	if err != nil {
+		return false, err
+	}
+	if err := c.reclaimVolumes(pCluster, ids); err != nil {
+		return false, err
+	}
+
+	// 5. 命名空间中没有其他集群时回收 serviceaccount 和 clusterrolebinding
+	clusters, err := c.clusterLister.PatroniClusters(ns).List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	for _, other := range clusters {
+		if other.Name != pCluster.Name {
+			return true, nil
+		}
+	}
+
+	revoked, err := c.revokePermission(ns)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonPermissionRevoked, "revoke patroni permission for namespace %s", ns)
+	}
+
+	return true, nil
+}
+
+func (c *patroniClusterController) deleteDCSObjects(namespace, clusterSelector string) (int, error) {
+
+	deleted := 0
+
+	endpoints, err := c.kubernetesCli.CoreV1().Endpoints(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return deleted, err
+	}
+	for _, ep := range endpoints.Items {
+		if err := c.kubernetesCli.CoreV1().Endpoints(namespace).Delete(context.Background(), ep.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return deleted, errors.Wrapf(err, "delete endpoints %s/%s failed", namespace, ep.Name)
+		}
+		deleted++
+	}
+
+	configMaps, err := c.kubernetesCli.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return deleted, err
+	}
+	for _, cm := range configMaps.Items {
+		if err := c.kubernetesCli.CoreV1().ConfigMaps(namespace).Delete(context.Background(), cm.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return deleted, errors.Wrapf(err, "delete configmap %s/%s failed", namespace, cm.Name)
+		}
+		deleted++
+	}
+
+	services, err := c.kubernetesCli.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return deleted, err
+	}
+	for _, svc := range services.Items {
+		if err := c.kubernetesCli.CoreV1().Services(namespace).Delete(context.Background(), svc.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return deleted, errors.Wrapf(err, "delete service %s/%s failed", namespace, svc.Name)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// clusterVolumeIds 集群成员数据卷的 statefulset-id，除 NodeList 中的成员外，还包括仍然存在的成员 statefulset
+// 和带有集群标签的数据卷，缩容中途或移除成员失败后残留的数据卷同样按回收策略处理
+func (c *patroniClusterController) clusterVolumeIds(pCluster *clusterv1alpha1.PatroniCluster, stsList []appsv1.StatefulSet) ([]string, error) {
+
+	ids := sets.NewString()
+	for _, n := range pCluster.PatroniClusterSpec.NodeList {
+		ids.Insert(fmt.Sprintf("%s-%s", pCluster.Name, n))
+	}
+	for _, sts := range stsList {
+		if id := sts.Labels["statefulset-id"]; id != "" {
+			ids.Insert(id)
+		}
+	}
+
+	selector := labels.SelectorFromSet(map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}).String()
+	pvcList, err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(pCluster.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcList.Items {
+		if id := pvc.Labels["statefulset-id"]; id != "" {
+			ids.Insert(id)
+		}
+	}
+
+	return ids.List(), nil
+}
+
+// reclaimVolumes 根据集群的回收策略删除或保留成员数据卷，ids 为成员的 statefulset-id
+func (c *patroniClusterController) reclaimVolumes(pCluster *clusterv1alpha1.PatroniCluster, ids []string) error {
+
+	if pCluster.PatroniClusterSpec.VolumeReclaimPolicy == clusterv1alpha1.VolumeDelete {
+		deleted, err := c.deleteVolumes(pCluster, ids)
+		if err != nil {
+			return err
+		}
+		if deleted != 0 {
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonVolumesDeleted, "delete %d patroni persistent volume claims", deleted)
+		}
+		return nil
+	}
+
+	retained, err := c.retainVolumes(pCluster, ids)
+	if err != nil {
+		return err
+	}
+	if retained != 0 {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonVolumesRetained, "retain %d patroni persistent volume claims", retained)
+	}
+	return nil
+}
+
+// memberVolumes 获取成员的数据卷，通过 statefulset-id 匹配以兼容缺少 cluster-name 标签的旧数据卷
+func (c *patroniClusterController) memberVolumes(namespace string, ids []string) ([]v1.PersistentVolumeClaim, error) {
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	idRequirement, err := labels.NewRequirement("statefulset-id", selection.In, ids)
+	if err != nil {
+		return nil, err
+	}
+	appRequirement, err := labels.NewRequirement("application", selection.Equals, []string{"patroni"})
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.NewSelector().Add(*appRequirement, *idRequirement).String()
+
+	pvcList, err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	return pvcList.Items, nil
+}
+
+// deleteVolumes 删除数据卷，仍被 Pod 使用的数据卷在 Pod 退出后才会真正删除
+func (c *patroniClusterController) deleteVolumes(pCluster *clusterv1alpha1.PatroniCluster, ids []string) (int, error) {
+
+	ns := pCluster.Namespace
+	pvcs, err := c.memberVolumes(ns, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, pvc := range pvcs {
+		if err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(ns).Delete(context.Background(), pvc.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return deleted, errors.Wrapf(err, "delete pvc %s/%s failed", ns, pvc.Name)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// retainVolumes 保留成员数据卷，补齐集群标签以便重建同名集群时重新挂载
+func (c *patroniClusterController) retainVolumes(pCluster *clusterv1alpha1.PatroniCluster, ids []string) (int, error) {
+
+	ns := pCluster.Namespace
+	pvcs, err := c.memberVolumes(ns, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		if pvc.Labels["cluster-name"] == pCluster.Name {
+			continue
+		}
+		if pvc.Labels == nil {
+			pvc.Labels = map[string]string{}
+		}
+		pvc.Labels["cluster-name"] = pCluster.Name
+		if _, err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(ns).Update(context.Background(), pvc, metav1.UpdateOptions{}); err != nil {
+			return 0, errors.Wrapf(err, "label pvc %s/%s failed", ns, pvc.Name)
+		}
+	}
+
+	return len(pvcs), nil
+}
+
+// revokePermission 删除 grantPermission 创建的 serviceaccount 和 clusterrolebinding
+func (c *patroniClusterController) revokePermission(namespace string) (bool, error) {
+
+	bindingName := fmt.Sprintf("%s:%s", namespace, defaultClusterRoleBinding)
+	err := c.kubernetesCli.RbacV1().ClusterRoleBindings().Delete(context.Background(), bindingName, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "delete clusterrolebinding %s failed", bindingName)
+	}
+	revoked := err == nil
+
+	sa, err := c.kubernetesCli.CoreV1().ServiceAccounts(namespace).Get(context.Background(), defaultServiceAccountName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return revoked, nil
+		}
+		return revoked, err
+	}
+
+	// 只回收控制器创建的 serviceaccount
+	if sa.Labels["rccp.ruijie.com.cn"] != "patroni-cluster-controller" {
+		return revoked, nil
+	}
+	err = c.kubernetesCli.CoreV1().ServiceAccounts(namespace).Delete(context.Background(), sa.Name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return revoked, errors.Wrapf(err, "delete serviceaccount %s/%s failed", namespace, sa.Name)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	clusterLister "pgoperator/pkg/client/listers/cluster/v1alpha1"
+	"sort"
+	"testing"
+)
+
+func newTestVolume(name string, labels map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+}
+
+// TestTeardownVolumes 删除集群时回收所有成员的数据卷，包括已经从 NodeList 移除但仍然残留的成员
+func TestTeardownVolumes(t *testing.T) {
+
+	tests := []struct {
+		policy   clusterv1alpha1.VolumeReclaimPolicy
+		expected string
+	}{
+		{clusterv1alpha1.VolumeDelete, "[data-pg-other-0]"},
+		{clusterv1alpha1.VolumeRetain, "[data-pg-a-0 data-pg-b-0 data-pg-c-0 data-pg-other-0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			pCluster := newTestCluster("a")
+			pCluster.PatroniClusterSpec.VolumeReclaimPolicy = tt.policy
+
+			kubernetesCli := fake.NewSimpleClientset(
+				// 缩容中途残留的成员 statefulset，数据卷缺少集群标签
+				&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "pg-b", Namespace: "default", Labels: map[string]string{
+					"application": "patroni", "cluster-name": "pg", "statefulset-id": "pg-b",
+				}}},
+				newTestVolume("data-pg-a-0", map[string]string{"application": "patroni", "statefulset-id": "pg-a"}),
+				newTestVolume("data-pg-b-0", map[string]string{"application": "patroni", "statefulset-id": "pg-b"}),
+				// 移除成员失败后残留的数据卷，statefulset 已经删除
+				newTestVolume("data-pg-c-0", map[string]string{"application": "patroni", "cluster-name": "pg", "statefulset-id": "pg-c"}),
+				newTestVolume("data-pg-other-0", map[string]string{"application": "patroni", "cluster-name": "other", "statefulset-id": "other-a"}),
+			)
+
+			clusters := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			clusters.Add(pCluster)
+			c := &patroniClusterController{
+				kubernetesCli: kubernetesCli,
+				eventRecorder: record.NewFakeRecorder(100),
+				clusterLister: clusterLister.NewPatroniClusterLister(clusters),
+			}
+
+			done, err := c.teardownCluster(pCluster)
+			if err != nil || !done {
+				t.Fatalf("teardown cluster failed: %v %v", done, err)
+			}
+
+			pvcs, err := kubernetesCli.CoreV1().PersistentVolumeClaims("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("list pvcs failed: %v", err)
+			}
+			var names []string
+			for _, pvc := range pvcs.Items {
+				names = append(names, pvc.Name)
+				if tt.policy == clusterv1alpha1.VolumeRetain && pvc.Name != "data-pg-other-0" && pvc.Labels["cluster-name"] != "pg" {
+					t.Errorf("expected retained pvc %s labeled with cluster-name", pvc.Name)
+				}
+			}
+			sort.Strings(names)
+			if fmt.Sprint(names) != tt.expected {
+				t.Errorf("expected pvcs %s, got %v", tt.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	"strings"
+)
+
+// 健康检查发现的异常，同时作为 Degraded 条件原因和事件类型
+const (
+	reasonSplitBrain       = "SplitBrain"
+	reasonLeaderMissing    = "LeaderMissing"
+	reasonStartFailed      = "StartFailed"
+	reasonMembersUnhealthy = "MembersUnhealthy"
+)
+
+// healthProblem 集群健康检查结果，没有异常时为空
+type healthProblem struct {
+	reason  string
+	message string
+}
+
+// checkClusterHealth 通过 Patroni REST API 检查集群健康状态，检测脑裂、缺少 leader 和启动失败的成员，
+// 异常首次出现或发生变化时记录事件
+func (c *patroniClusterController) checkClusterHealth(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) *healthProblem {
+
+	standby := standbyCluster(pCluster)
+	var leaders, startFailed, unhealthy []string
+	for _, m := range members {
+		if m.patroni != nil {
+			if m.patroni.IsLeader() || (standby && m.patroni.IsStandbyLeader()) {
+				leaders = append(leaders, m.podName())
+			}
+			if m.patroni.State == patroni.StateStartFailed {
+				startFailed = append(startFailed, m.podName())
+			}
+		}
+
+		if m.pod == nil || m.pod.Status.PodIP == "" {
+			unhealthy = append(unhealthy, m.podName())
+			continue
+		}
+		if err := c.patroniCli(m.pod.Status.PodIP).Health(context.Background()); err != nil {
+			klog.V(4).Infof("patroni member %s/%s is unhealthy: %v", pCluster.Namespace, m.podName(), err)
+			unhealthy = append(unhealthy, m.podName())
+		}
+	}
+
+	// 不同成员看到的 leader 不一致同样视为脑裂
+	views := map[string]bool{}
+	for _, m := range members {
+		if m.pod == nil || m.pod.Status.PodIP == "" || m.patroni == nil {
+			continue
+		}
+		info, err := c.patroniCli(m.pod.Status.PodIP).Cluster(context.Background())
+		if err != nil {
+			continue
+		}
+		if leader := info.Leader(); leader != nil {
+			views[leader.Name] = true
+		}
+	}
+
+	var problem *healthProblem
+	switch {
+	case len(leaders) > 1 || len(views) > 1:
+		var viewLeaders []string
+		for name := range views {
+			viewLeaders = append(viewLeaders, name)
+		}
+		problem = &healthProblem{
+			reason:  reasonSplitBrain,
+			message: fmt.Sprintf("multiple leaders detected, members report leader role: [%s], cluster views: [%s]", strings.Join(leaders, ","), strings.Join(viewLeaders, ",")),
+		}
+	case len(leaders) == 0 && len(members) != 0:
+		problem = &healthProblem{reason: reasonLeaderMissing, message: "no member is running as leader"}
+	case len(startFailed) != 0:
+		problem = &healthProblem{reason: reasonStartFailed, message: fmt.Sprintf("members start failed: [%s]", strings.Join(startFailed, ","))}
+	case len(unhealthy) != 0:
+		problem = &healthProblem{reason: reasonMembersUnhealthy, message: fmt.Sprintf("members are unhealthy: [%s]", strings.Join(unhealthy, ","))}
+	}
+
+	if problem == nil {
+		return nil
+	}
+
+	previous := meta.FindStatusCondition(pCluster.PatroniClusterStatus.Conditions, clusterv1alpha1.ConditionDegraded)
+	if previous == nil || previous.Status != metav1.ConditionTrue || previous.Reason != problem.reason {
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, problem.reason, problem.message)
+	}
+
+	return problem
+}
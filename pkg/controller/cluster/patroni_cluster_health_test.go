@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"context"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	"testing"
+)
+
+// topologyPatroniCli 所有成员健康，/cluster 返回相同的集群拓扑
+type topologyPatroniCli struct {
+	patroni.Interface
+	info *patroni.ClusterInfo
+}
+
+func (f *topologyPatroniCli) Health(ctx context.Context) error {
+	return nil
+}
+
+func (f *topologyPatroniCli) Cluster(ctx context.Context) (*patroni.ClusterInfo, error) {
+	return f.info, nil
+}
+
+// TestStandbyClusterHealth 备库集群的 standby leader 视为 leader
+func TestStandbyClusterHealth(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		standby bool
+		// 成员 a 的 /patroni 角色
+		role    string
+		problem string
+		leader  string
+	}{
+		{name: "standby leader", standby: true, role: patroni.RoleStandbyLeader, leader: patroni.RoleStandbyLeader},
+		{name: "promoted leader", standby: true, role: "primary", leader: patroni.RoleLeader},
+		{name: "standby leader outside standby cluster", role: patroni.RoleStandbyLeader, problem: reasonLeaderMissing, leader: patroni.RoleStandbyLeader},
+		{name: "leader", role: "master", leader: patroni.RoleLeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pCluster := newTestCluster("a", "b")
+			if tt.standby {
+				pCluster.PatroniClusterStatus.Standby = &clusterv1alpha1.StandbyStatus{Phase: clusterv1alpha1.StandbyFollowing}
+			}
+			a := newTestMember(pCluster, "a", newImage, tt.role)
+			b := newTestMember(pCluster, "b", newImage, patroni.RoleReplica)
+			members := []*clusterMember{a, b}
+
+			// /cluster 接口不返回成员 a，角色只能来自 /patroni
+			info := &patroni.ClusterInfo{Members: []patroni.Member{{Name: b.podName(), Role: patroni.RoleReplica, State: patroni.StateRunning}}}
+			c := &patroniClusterController{
+				eventRecorder: record.NewFakeRecorder(10),
+				patroniCli: func(host string) patroni.Interface {
+					return &topologyPatroniCli{info: info}
+				},
+			}
+
+			problem := c.checkClusterHealth(pCluster, members)
+			reason := ""
+			if problem != nil {
+				reason = problem.reason
+			}
+			if reason != tt.problem {
+				t.Fatalf("expected problem %q, got %q", tt.problem, reason)
+			}
+
+			c.syncClusterStatus(pCluster, members, "", problem)
+			status := pCluster.PatroniClusterStatus
+			if status.Leader != a.podName() {
+				t.Errorf("expected leader %s, got %s", a.podName(), status.Leader)
+			}
+			if role := status.Members[0].Role; role != tt.leader {
+				t.Errorf("expected leader role %s, got %s", tt.leader, role)
+			}
+		})
+	}
+}
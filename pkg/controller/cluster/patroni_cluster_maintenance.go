@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"time"
+	// 控制器镜像中可能没有时区数据
+	_ "time/tzdata"
+)
+
+// 事件类型
+const (
+	reasonInvalidMaintenanceWindow = "InvalidMaintenanceWindow"
+)
+
+var weekdays = map[clusterv1alpha1.Weekday]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// maintenanceState 本次调谐时的维护窗口状态，记录因不在窗口内而推迟的操作
+type maintenanceState struct {
+	open    bool
+	next    time.Time
+	pending []string
+}
+
+// deferred 窗口关闭时记录推迟的操作并返回 true
+func (s *maintenanceState) deferred(operation string) bool {
+	if s.open {
+		return false
+	}
+	s.pending = append(s.pending, operation)
+	return true
+}
+
+// requeueAfter 到下一个维护窗口打开的时间，不超过 limit
+func (s *maintenanceState) requeueAfter(limit time.Duration) time.Duration {
+	if s.next.IsZero() {
+		return limit
+	}
+	if d := time.Until(s.next); d > 0 && d < limit {
+		return d
+	}
+	return limit
+}
+
+// maintenanceWindow 计算当前是否处于维护窗口内以及下一个窗口的开始时间，没有配置窗口时始终允许维护
+func maintenanceWindow(pCluster *clusterv1alpha1.PatroniCluster, now time.Time) (*maintenanceState, error) {
+
+	windows := pCluster.PatroniClusterSpec.MaintenanceWindows
+	if len(windows) == 0 {
+		return &maintenanceState{open: true}, nil
+	}
+
+	state := &maintenanceState{}
+	for _, w := range windows {
+		open, next, err := evaluateWindow(w, now)
+		if err != nil {
+			return nil, err
+		}
+		if open {
+			state.open = true
+		}
+		if state.next.IsZero() || next.Before(state.next) {
+			state.next = next
+		}
+	}
+
+	return state, nil
+}
+
+// evaluateWindow 返回 now 是否在窗口内以及窗口下一次开始的时间
+func evaluateWindow(w clusterv1alpha1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+
+	location := time.UTC
+	if w.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(w.TimeZone); err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid maintenance window time zone %q: %v", w.TimeZone, err)
+		}
+	}
+
+	start, err := time.Parse("15:04", w.StartTime)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid maintenance window start time %q", w.StartTime)
+	}
+	end, err := time.Parse("15:04", w.EndTime)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid maintenance window end time %q", w.EndTime)
+	}
+
+	days := map[time.Weekday]bool{}
+	for _, d := range w.Days {
+		weekday, ok := weekdays[d]
+		if !ok {
+			return false, time.Time{}, fmt.Errorf("invalid maintenance window day %q", d)
+		}
+		days[weekday] = true
+	}
+
+	local := now.In(location)
+	open := false
+	var next time.Time
+	// 从前一天开始检查，覆盖跨越零点的窗口
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, location)
+		if len(days) != 0 && !days[day.Weekday()] {
+			continue
+		}
+
+		windowStart := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, location)
+		windowEnd := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, location)
+		if !windowEnd.After(windowStart) {
+			windowEnd = windowEnd.AddDate(0, 0, 1)
+		}
+
+		if !local.Before(windowStart) && local.Before(windowEnd) {
+			open = true
+		}
+		if windowStart.After(local) && (next.IsZero() || windowStart.Before(next)) {
+			next = windowStart
+		}
+	}
+
+	return open, next, nil
+}
+
+// syncMaintenanceStatus 记录等待维护窗口的操作
+func syncMaintenanceStatus(pCluster *clusterv1alpha1.PatroniCluster, state *maintenanceState) {
+
+	if len(state.pending) == 0 {
+		pCluster.PatroniClusterStatus.PendingMaintenance = nil
+		return
+	}
+
+	status := &clusterv1alpha1.MaintenanceStatus{
+		Pending: state.pending,
+	}
+	if !state.next.IsZero() {
+		next := metav1.NewTime(state.next)
+		status.NextWindow = &next
+	}
+	pCluster.PatroniClusterStatus.PendingMaintenance = status
+}
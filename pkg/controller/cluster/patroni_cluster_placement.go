@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	coreV1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"pgoperator/pkg/apis/cluster/v1alpha1"
+)
+
+// 事件类型
+const (
+	reasonInvalidMember = "InvalidMember"
+)
+
+// memberSpec 获取成员的调度配置，未配置时返回 nil
+func memberSpec(pCluster *v1alpha1.PatroniCluster, indexName string) *v1alpha1.MemberSpec {
+	for i := range pCluster.PatroniClusterSpec.Members {
+		if pCluster.PatroniClusterSpec.Members[i].Name == indexName {
+			return &pCluster.PatroniClusterSpec.Members[i]
+		}
+	}
+	return nil
+}
+
+// nodeAffinitySet 将成员固定到指定节点，通过 metadata.name 字段匹配避免依赖 hostname 标签
+func nodeAffinitySet(member *v1alpha1.MemberSpec) *coreV1.NodeAffinity {
+
+	if member == nil || member.NodeName == "" {
+		return nil
+	}
+
+	return &coreV1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &coreV1.NodeSelector{
+			NodeSelectorTerms: []coreV1.NodeSelectorTerm{
+				{
+					MatchFields: []coreV1.NodeSelectorRequirement{
+						{
+							Key:      "metadata.name",
+							Operator: coreV1.NodeSelectorOpIn,
+							Values:   []string{member.NodeName},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// checkMembers 检查成员调度配置引用的成员和节点是否存在
+func (c *patroniClusterController) checkMembers(pCluster *v1alpha1.PatroniCluster) error {
+
+	nodeList := sets.NewString(pCluster.PatroniClusterSpec.NodeList...)
+
+	for _, member := range pCluster.PatroniClusterSpec.Members {
+		if !nodeList.Has(member.Name) {
+			return fmt.Errorf("member %q in spec.members not found in spec.nodeList", member.Name)
+		}
+		if member.NodeName == "" {
+			continue
+		}
+
+		_, err := c.kubernetesCli.CoreV1().Nodes().Get(context.Background(), member.NodeName, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return fmt.Errorf("node %s of member %q not found", member.NodeName, member.Name)
+			}
+			return errors.Wrapf(err, "get node %s failed", member.NodeName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"strings"
+)
+
+// 事件类型
+const (
+	reasonVolumeExpanding            = "VolumeExpanding"
+	reasonVolumeExpansionUnsupported = "VolumeExpansionUnsupported"
+	reasonVolumeExpansionFailed      = "VolumeExpansionFailed"
+	reasonVolumeExpansionCompleted   = "VolumeExpansionCompleted"
+	reasonMemberTemplateRecreated    = "MemberTemplateRecreated"
+)
+
+// expandVolumes 扩容成员已有的数据卷：
+// 1. 存储容量增大时更新成员 PVC 的容量请求，要求 StorageClass 允许扩容
+// 2. statefulset 的 volumeClaimTemplates 不可修改，以 orphan 方式删除后由 initCluster 重建，Pod 不会重启
+// 返回是否有数据卷正在扩容
+func (c *patroniClusterController) expandVolumes(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) (bool, error) {
+
+	ns := pCluster.Namespace
+	var pending, failed []string
+	fsPending := false
+
+	for _, m := range members {
+		if m.live == nil {
+			continue
+		}
+
+		memberFailed := false
+		for _, vct := range m.desired.Spec.VolumeClaimTemplates {
+			want := vct.Spec.Resources.Requests[v1.ResourceStorage]
+			pvcName := fmt.Sprintf("%s-%s", vct.Name, m.podName())
+
+			pvc, err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(ns).Get(context.Background(), pvcName, metav1.GetOptions{})
+			if err != nil {
+				if k8serrors.IsNotFound(err) {
+					continue
+				}
+				return false, err
+			}
+
+			requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			if want.Cmp(requested) > 0 {
+				if err := c.expandVolume(pCluster, pvc, want); err != nil {
+					klog.Error(errors.Wrapf(err, "expand pvc %s/%s failed", ns, pvcName))
+					failed = append(failed, fmt.Sprintf("%s: %v", pvcName, err))
+					memberFailed = true
+					continue
+				}
+				pending = append(pending, pvcName)
+				continue
+			}
+
+			capacity := pvc.Status.Capacity[v1.ResourceStorage]
+			if capacity.Cmp(requested) < 0 {
+				pending = append(pending, pvcName)
+				if volumeCondition(pvc, v1.PersistentVolumeClaimFileSystemResizePending) {
+					fsPending = true
+				}
+			}
+		}
+
+		// 成员有待应用的模板变更时先等待滚动更新完成，避免重建 statefulset 时重启 Pod
+		if !memberFailed && len(m.diff) == 0 && claimTemplatesOutdated(m.live, &m.desired) {
+			if err := c.recreateMemberTemplate(pCluster, m); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	c.syncResizeStatus(pCluster, pending, failed, fsPending)
+	return len(pending) != 0, nil
+}
+
+// expandVolume 更新 PVC 的容量请求，由存储驱动完成扩容
+func (c *patroniClusterController) expandVolume(pCluster *clusterv1alpha1.PatroniCluster, pvc *v1.PersistentVolumeClaim, size resource.Quantity) error {
+
+	expandable, err := c.volumeExpandable(pvc)
+	if err != nil {
+		return err
+	}
+	if !expandable {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonVolumeExpansionUnsupported,
+			"storageclass of pvc %s does not allow volume expansion", pvc.Name)
+		return fmt.Errorf("storageclass does not allow volume expansion")
+	}
+
+	pvc = pvc.DeepCopy()
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = size
+	if _, err := c.kubernetesCli.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(context.Background(), pvc, metav1.UpdateOptions{}); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonVolumeExpansionFailed, "expand pvc %s to %s failed: %v", pvc.Name, size.String(), err)
+		return err
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonVolumeExpanding, "expand pvc %s to %s", pvc.Name, size.String())
+	return nil
+}
+
+// volumeExpandable 检查 PVC 使用的 StorageClass 是否允许扩容
+func (c *patroniClusterController) volumeExpandable(pvc *v1.PersistentVolumeClaim) (bool, error) {
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+
+	sc, err := c.kubernetesCli.StorageV1().StorageClasses().Get(context.Background(), *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}
+
+// recreateMemberTemplate 以 orphan 方式删除成员 statefulset，保留 Pod、PVC 和 ControllerRevision，
+// initCluster 使用新的 volumeClaimTemplates 重建后接管原有 Pod
+func (c *patroniClusterController) recreateMemberTemplate(pCluster *clusterv1alpha1.PatroniCluster, m *clusterMember) error {
+
+	if m.live.DeletionTimestamp != nil {
+		return nil
+	}
+
+	orphan := metav1.DeletePropagationOrphan
+	err := c.kubernetesCli.AppsV1().StatefulSets(m.live.Namespace).Delete(context.Background(), m.live.Name, metav1.DeleteOptions{PropagationPolicy: &orphan})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete statefulset %s/%s with orphan policy failed", m.live.Namespace, m.live.Name)
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonMemberTemplateRecreated, "recreate patroni member statefulset %s to apply new volume claim templates", m.live.Name)
+	return nil
+}
+
+// claimTemplatesOutdated 期望的数据卷容量是否大于线上 statefulset 模板中的容量
+func claimTemplatesOutdated(live, desired *appsv1.StatefulSet) bool {
+
+	liveSize := map[string]resource.Quantity{}
+	for _, vct := range live.Spec.VolumeClaimTemplates {
+		liveSize[vct.Name] = vct.Spec.Resources.Requests[v1.ResourceStorage]
+	}
+
+	for _, vct := range desired.Spec.VolumeClaimTemplates {
+		size, ok := liveSize[vct.Name]
+		if !ok {
+			continue
+		}
+		want := vct.Spec.Resources.Requests[v1.ResourceStorage]
+		if want.Cmp(size) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func volumeCondition(pvc *v1.PersistentVolumeClaim, conditionType v1.PersistentVolumeClaimConditionType) bool {
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// syncResizeStatus 记录扩容进度，没有进行中的扩容时保留最后一次扩容的结果
+func (c *patroniClusterController) syncResizeStatus(pCluster *clusterv1alpha1.PatroniCluster, pending, failed []string, fsPending bool) {
+
+	resize := pCluster.PatroniClusterStatus.Resize
+	if len(pending) == 0 && len(failed) == 0 {
+		if resize != nil && resize.Phase != clusterv1alpha1.ResizeCompleted {
+			resize = resize.DeepCopy()
+			now := metav1.Now()
+			resize.Phase = clusterv1alpha1.ResizeCompleted
+			resize.PendingVolumes = nil
+			resize.Message = ""
+			resize.CompletionTime = &now
+			pCluster.PatroniClusterStatus.Resize = resize
+			c.eventRecorder.Event(pCluster, v1.EventTypeNormal, reasonVolumeExpansionCompleted, "patroni member volumes expanded")
+		}
+		return
+	}
+
+	if resize == nil || resize.Phase == clusterv1alpha1.ResizeCompleted {
+		now := metav1.Now()
+		resize = &clusterv1alpha1.ResizeStatus{StartTime: &now}
+	} else {
+		resize = resize.DeepCopy()
+	}
+
+	resize.PendingVolumes = pending
+	resize.Message = ""
+	switch {
+	case len(failed) != 0:
+		resize.Phase = clusterv1alpha1.ResizeFailed
+		resize.Message = strings.Join(failed, "; ")
+	case fsPending:
+		resize.Phase = clusterv1alpha1.ResizeFileSystemPending
+		resize.Message = "restart member pods to finish file system resize"
+	default:
+		resize.Phase = clusterv1alpha1.ResizeInProgress
+	}
+	pCluster.PatroniClusterStatus.Resize = resize
+}
@@ -0,0 +1,196 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+)
+
+// 事件类型
+const (
+	reasonMemberRemoved  = "MemberRemoved"
+	reasonScaleInBlocked = "ScaleInBlocked"
+	reasonScaleInFailed  = "ScaleInFailed"
+	reasonScaleInWaiting = "ScaleInWaiting"
+)
+
+// removedMembers 获取已经从 NodeList 中移除但 statefulset 仍然存在的成员
+func (c *patroniClusterController) removedMembers(pCluster *clusterv1alpha1.PatroniCluster) ([]*clusterMember, error) {
+
+	ns := pCluster.Namespace
+	clusterSelector := labels.SelectorFromSet(map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}).String()
+
+	stsList, err := c.kubernetesCli.AppsV1().StatefulSets(ns).List(context.Background(), metav1.ListOptions{LabelSelector: clusterSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	desired := sets.NewString()
+	for _, n := range pCluster.PatroniClusterSpec.NodeList {
+		desired.Insert(generatorStatefulset(n, pCluster).Name)
+	}
+
+	var removed []*clusterMember
+	for i := range stsList.Items {
+		live := &stsList.Items[i]
+		if desired.Has(live.Name) {
+			continue
+		}
+		m := &clusterMember{
+			name:    live.Name,
+			desired: *live,
+			live:    live,
+		}
+		if err := c.loadMemberState(ns, m); err != nil {
+			return nil, err
+		}
+		removed = append(removed, m)
+	}
+
+	return removed, nil
+}
+
+// scaleIn 删除已经从 NodeList 中移除的成员，每次调谐最多删除一个：
+// 移除成员数超过 maxScaleInMembers 时拒绝缩容，待删除成员为 leader 时先切换到其他健康成员
+func (c *patroniClusterController) scaleIn(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember, window *maintenanceState) (rollAction, error) {
+
+	removed, err := c.removedMembers(pCluster)
+	if err != nil {
+		return rollNone, err
+	}
+	if len(removed) == 0 {
+		c.scaleInUnblocked(pCluster)
+		return rollNone, nil
+	}
+
+	limit := int(pCluster.PatroniClusterSpec.MaxScaleInMembers)
+	if limit < 1 {
+		limit = 1
+	}
+	if len(removed) > limit {
+		c.scaleInBlocked(pCluster, reasonScaleInBlocked,
+			fmt.Sprintf("refuse to remove %d patroni members at once, maxScaleInMembers is %d", len(removed), limit))
+		return rollNone, nil
+	}
+
+	// 有成员未就绪时不做变更，避免缩容后剩余成员无法提供服务
+	for _, m := range members {
+		if !m.ready() {
+			klog.V(4).Infof("patroni member %s/%s not ready, waiting before scale in...", pCluster.Namespace, m.desired.Name)
+			return rollWaiting, nil
+		}
+	}
+
+	target := removed[0]
+	for _, m := range removed {
+		if !m.leader() {
+			target = m
+			break
+		}
+	}
+
+	// 不能直接删除 leader，先切换到保留成员中的健康 replica
+	if target.leader() {
+		if window.deferred(fmt.Sprintf("switchover from %s before removing it", target.podName())) {
+			return rollPending, nil
+		}
+		candidate := switchoverCandidate(members)
+		if candidate == nil {
+			c.scaleInBlocked(pCluster, reasonScaleInWaiting,
+				fmt.Sprintf("patroni member %s is leader and no healthy replica available for switchover", target.podName()))
+			return rollWaiting, nil
+		}
+		c.scaleInUnblocked(pCluster)
+		if err := c.switchover(pCluster, target, candidate); err != nil {
+			return rollNone, err
+		}
+		return rollSwitchover, nil
+	}
+	c.scaleInUnblocked(pCluster)
+
+	if err := c.removeMember(pCluster, target); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonScaleInFailed, "remove patroni member %s failed: %v", target.desired.Name, err)
+		return rollNone, err
+	}
+
+	return rollScaleIn, nil
+}
+
+// scaleInBlocked 记录缩容无法继续的原因，原因首次出现或发生变化时记录事件，避免每次调谐重复记录
+func (c *patroniClusterController) scaleInBlocked(pCluster *clusterv1alpha1.PatroniCluster, reason, message string) {
+
+	previous := meta.FindStatusCondition(pCluster.PatroniClusterStatus.Conditions, clusterv1alpha1.ConditionScaleInBlocked)
+	if previous == nil || previous.Reason != reason || previous.Message != message {
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reason, message)
+	}
+	setCondition(pCluster, clusterv1alpha1.ConditionScaleInBlocked, metav1.ConditionTrue, reason, message)
+}
+
+func (c *patroniClusterController) scaleInUnblocked(pCluster *clusterv1alpha1.PatroniCluster) {
+	meta.RemoveStatusCondition(&pCluster.PatroniClusterStatus.Conditions, clusterv1alpha1.ConditionScaleInBlocked)
+}
+
+// removeMember 删除成员 statefulset 并根据回收策略处理数据卷。
+// kubernetes DCS 中成员信息保存在 Pod 注解上，Pod 删除后成员即从 DCS 中移除
+func (c *patroniClusterController) removeMember(pCluster *clusterv1alpha1.PatroniCluster, m *clusterMember) error {
+
+	ns := pCluster.Namespace
+	err := c.kubernetesCli.AppsV1().StatefulSets(ns).Delete(context.Background(), m.live.Name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete statefulset %s/%s failed", ns, m.live.Name)
+	}
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonMemberRemoved, "remove patroni member %s", m.live.Name)
+
+	// 数据卷受 pvc-protection 保护，Pod 退出后才会真正删除
+	return c.reclaimVolumes(pCluster, []string{m.live.Name})
+}
+
+// scaleOutReady 扩容时逐个创建新成员，所有已创建的成员健康并且 replica 开始流复制后才创建下一个，
+// 避免多个新成员同时从 leader 执行 basebackup
+func (c *patroniClusterController) scaleOutReady(pCluster *clusterv1alpha1.PatroniCluster) (bool, error) {
+
+	members, err := c.listMembers(pCluster)
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range members {
+		if m.live == nil {
+			continue
+		}
+		if !m.healthy() || (!m.leader() && !m.patroni.Streaming()) {
+			klog.V(4).Infof("patroni member %s/%s is not streaming, waiting before scale out...", pCluster.Namespace, m.podName())
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// scalingOut 是否有新加入的成员仍在初始化
+func scalingOut(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) bool {
+
+	known := sets.NewString()
+	for _, ms := range pCluster.PatroniClusterStatus.Members {
+		if ms.Ready {
+			known.Insert(ms.Name)
+		}
+	}
+
+	for _, m := range members {
+		if !m.ready() && !known.Has(m.podName()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/utils/owner"
+	"reflect"
+)
+
+const (
+	reasonServiceCreated = "ServiceCreated"
+	reasonServiceUpdated = "ServiceUpdated"
+)
+
+// Patroni 为 leader 和 replica 设置的角色标签值，leader 的标签值可以通过 spec.patroni.leaderLabelValue 修改
+const (
+	patroniMasterRole  = "master"
+	patroniReplicaRole = "replica"
+)
+
+// syncServices 创建集群的 headless 复制服务和应用访问使用的读写、只读服务，并记录服务地址
+func (c *patroniClusterController) syncServices(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	headless := generatorService(pCluster, fmt.Sprintf("%s-repl", pCluster.Name), "")
+	headless.Spec.ClusterIP = v1.ClusterIPNone
+	// 成员引导阶段需要通过 DNS 解析未就绪的 Pod
+	headless.Spec.PublishNotReadyAddresses = true
+
+	primary := generatorService(pCluster, primaryServiceName(pCluster.Name), leaderLabelValue(pCluster))
+	replicas := generatorService(pCluster, replicasServiceName(pCluster.Name), patroniReplicaRole)
+
+	for _, svc := range []*v1.Service{headless, primary, replicas} {
+		if err := c.ensureService(pCluster, svc); err != nil {
+			return err
+		}
+	}
+
+	pCluster.PatroniClusterStatus.Services = &clusterv1alpha1.ServiceStatus{
+		Primary:  serviceDNSName(primary),
+		Replicas: serviceDNSName(replicas),
+		Headless: serviceDNSName(headless),
+	}
+	return nil
+}
+
+func (c *patroniClusterController) ensureService(pCluster *clusterv1alpha1.PatroniCluster, svc *v1.Service) error {
+
+	live, err := c.kubernetesCli.CoreV1().Services(svc.Namespace).Get(context.Background(), svc.Name, metav1.GetOptions{})
+	if err == nil {
+		// leader 的角色标签值变化时更新选择器
+		if reflect.DeepEqual(live.Spec.Selector, svc.Spec.Selector) {
+			return nil
+		}
+		live = live.DeepCopy()
+		live.Spec.Selector = svc.Spec.Selector
+		if _, err := c.kubernetesCli.CoreV1().Services(svc.Namespace).Update(context.Background(), live, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "update service %s/%s failed", svc.Namespace, svc.Name)
+		}
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonServiceUpdated, "update selector of service %s", svc.Name)
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	if _, err := c.kubernetesCli.CoreV1().Services(svc.Namespace).Create(context.Background(), svc, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "create service %s/%s failed", svc.Namespace, svc.Name)
+	}
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonServiceCreated, "create service %s", svc.Name)
+	return nil
+}
+
+// generatorService 生成选择集群成员的服务，role 不为空时只选择对应角色的成员
+func generatorService(pCluster *clusterv1alpha1.PatroniCluster, name, role string) *v1.Service {
+
+	labels := map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}
+
+	selector := map[string]string{
+		"application":  "patroni",
+		"cluster-name": pCluster.Name,
+	}
+	if role != "" {
+		selector[patroniRoleLabel] = role
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pCluster.Namespace,
+			Labels:    labels,
+		},
+		Spec: v1.ServiceSpec{
+			Selector: selector,
+			Ports: []v1.ServicePort{
+				{
+					Name:       "postgresql",
+					Port:       5432,
+					TargetPort: intstr.FromInt(5432),
+					Protocol:   v1.ProtocolTCP,
+				},
+				{
+					Name:       "patroni",
+					Port:       8008,
+					TargetPort: intstr.FromInt(8008),
+					Protocol:   v1.ProtocolTCP,
+				},
+			},
+		},
+	}
+	owner.AddOwnerRef(pCluster, svc, clusterv1alpha1.SchemeGroupVersion.WithKind("PatroniCluster"))
+
+	return svc
+}
+
+func primaryServiceName(pClusterName string) string {
+	return fmt.Sprintf("%s-primary", pClusterName)
+}
+
+func replicasServiceName(pClusterName string) string {
+	return fmt.Sprintf("%s-replicas", pClusterName)
+}
+
+func serviceDNSName(svc *v1.Service) string {
+	return fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+}
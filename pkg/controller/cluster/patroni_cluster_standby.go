@@ -0,0 +1,292 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+)
+
+// 事件类型
+const (
+	reasonInvalidStandby          = "InvalidStandby"
+	reasonStandbyPromoting        = "StandbyPromoting"
+	reasonStandbyPromoted         = "StandbyPromoted"
+	reasonStandbyPromotionWaiting = "StandbyPromotionWaiting"
+	reasonStandbyPromotionFailed  = "StandbyPromotionFailed"
+)
+
+const (
+	standbyClusterConfigKey = "standby_cluster"
+	defaultStandbyPort      = 5432
+	// 与 Patroni maximum_lag_on_failover 的默认值一致
+	defaultMaximumLagOnPromotion = 1048576
+)
+
+// standbySource 复制源地址，引用 PatroniCluster 时使用其 primary 服务
+func standbySource(pCluster *clusterv1alpha1.PatroniCluster) (string, int32) {
+
+	standby := pCluster.PatroniClusterSpec.Standby
+	port := standby.Port
+	if port == 0 {
+		port = defaultStandbyPort
+	}
+	if standby.ClusterName != "" {
+		return fmt.Sprintf("%s.%s.svc", primaryServiceName(standby.ClusterName), standbySourceNamespace(pCluster)), port
+	}
+	return standby.Host, port
+}
+
+func standbySourceNamespace(pCluster *clusterv1alpha1.PatroniCluster) string {
+	if ns := pCluster.PatroniClusterSpec.Standby.Namespace; ns != "" {
+		return ns
+	}
+	return pCluster.Namespace
+}
+
+// standbyConfig 动态配置中的 standby_cluster，首个成员据此从远端主库创建副本而不是执行 initdb
+func standbyConfig(pCluster *clusterv1alpha1.PatroniCluster) map[string]interface{} {
+
+	standby := pCluster.PatroniClusterSpec.Standby
+	host, port := standbySource(pCluster)
+	config := map[string]interface{}{
+		"host":                   host,
+		"port":                   port,
+		"create_replica_methods": []string{"basebackup"},
+	}
+	if standby.RestoreCommand != "" {
+		config["restore_command"] = standby.RestoreCommand
+	}
+	if standby.PrimarySlotName != "" {
+		config["primary_slot_name"] = standby.PrimarySlotName
+	}
+	return config
+}
+
+// checkStandby 校验备库配置，运行中的主集群不能降级为备库
+func (c *patroniClusterController) checkStandby(pCluster *clusterv1alpha1.PatroniCluster) error {
+
+	standby := pCluster.PatroniClusterSpec.Standby
+	if standby == nil {
+		return nil
+	}
+
+	if customBootstrap(pCluster) {
+		return fmt.Errorf("spec.standby can not be used together with spec.bootstrap")
+	}
+	if (standby.Host == "") == (standby.ClusterName == "") {
+		return fmt.Errorf("one of host and clusterName must be specified in spec.standby")
+	}
+	if pCluster.PatroniClusterStatus.Status == clusterv1alpha1.ClusterRunning && pCluster.PatroniClusterStatus.Standby == nil {
+		return fmt.Errorf("spec.standby can only be set when the cluster is created")
+	}
+	if standby.ClusterName == "" {
+		return nil
+	}
+
+	ns := standbySourceNamespace(pCluster)
+	if ns == pCluster.Namespace && standby.ClusterName == pCluster.Name {
+		return fmt.Errorf("patroni cluster can not follow itself")
+	}
+	source, err := c.clusterLister.PatroniClusters(ns).Get(standby.ClusterName)
+	if err != nil {
+		return errors.Wrapf(err, "get source cluster %s/%s failed", ns, standby.ClusterName)
+	}
+
+	// standby leader 使用本集群的复制用户连接源集群
+	if replicationUserName(source) != replicationUserName(pCluster) {
+		return fmt.Errorf("replication user %s differs from source cluster user %s", replicationUserName(pCluster), replicationUserName(source))
+	}
+	sourceSecret, err := c.kubernetesCli.CoreV1().Secrets(ns).Get(context.Background(), source.PatroniClusterSpec.ReplicationUserSecretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "get replication secret of source cluster %s/%s failed", ns, source.Name)
+	}
+	secret, err := c.kubernetesCli.CoreV1().Secrets(pCluster.Namespace).Get(context.Background(), pCluster.PatroniClusterSpec.ReplicationUserSecretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "get replication secret of patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+	if string(secret.Data[secretPasswordKey]) != string(sourceSecret.Data[secretPasswordKey]) {
+		return fmt.Errorf("replication password in secret %s differs from source cluster secret %s/%s",
+			secret.Name, ns, sourceSecret.Name)
+	}
+
+	return nil
+}
+
+// standbyCluster 备库集群在提升完成之前由 standby leader 承担 leader 角色
+func standbyCluster(pCluster *clusterv1alpha1.PatroniCluster) bool {
+	return pCluster.PatroniClusterSpec.Standby != nil || pCluster.PatroniClusterStatus.Standby != nil
+}
+
+// promoteStandby 删除 spec.standby 后将备库集群提升为主集群：
+// standby leader 健康且复制延迟不超过 maximum_lag_on_failover 时删除 DCS 中的 standby_cluster，
+// 由 Patroni 提升 standby leader，出现 leader 后提升完成
+func (c *patroniClusterController) promoteStandby(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) (rollAction, error) {
+
+	status := pCluster.PatroniClusterStatus.Standby
+	if status == nil || pCluster.PatroniClusterSpec.Standby != nil {
+		return rollNone, nil
+	}
+
+	var leader *clusterMember
+	for _, m := range members {
+		if m.leader() {
+			leader = m
+			break
+		}
+	}
+
+	if leader != nil && leader.patroni != nil && leader.patroni.IsLeader() {
+		pCluster.PatroniClusterStatus.Standby = nil
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonStandbyPromoted, "standby cluster promoted, %s is the leader", leader.podName())
+		return rollNone, nil
+	}
+
+	if leader == nil || !leader.healthy() {
+		c.promotionWaiting(pCluster, "waiting for a healthy standby leader to promote")
+		return rollPromote, nil
+	}
+
+	if status.Phase != clusterv1alpha1.StandbyPromoting {
+		maxLag := int64(defaultMaximumLagOnPromotion)
+		if spec := pCluster.PatroniClusterSpec.Patroni; spec != nil && spec.MaximumLagOnFailover != nil {
+			maxLag = *spec.MaximumLagOnFailover
+		}
+		// 源集群仍然可以访问时等待追平，源集群不可用时无法计算延迟，直接提升
+		if status.Lag != nil && *status.Lag > maxLag {
+			c.promotionWaiting(pCluster, fmt.Sprintf("standby leader %s is %d bytes behind the source, waiting to catch up", leader.podName(), *status.Lag))
+			return rollPromote, nil
+		}
+	}
+
+	// 提升过程中重复检查，PATCH 未生效时重新执行
+	cli := c.patroniCli(leader.pod.Status.PodIP)
+	config, err := cli.Config(context.Background())
+	if err != nil {
+		return rollPromote, errors.Wrapf(err, "get patroni config from %s failed", leader.podName())
+	}
+	if _, ok := config[standbyClusterConfigKey]; !ok && status.Phase == clusterv1alpha1.StandbyPromoting {
+		klog.V(4).Infof("waiting for standby leader %s/%s to be promoted", pCluster.Namespace, leader.podName())
+		return rollPromote, nil
+	}
+
+	if status.Phase != clusterv1alpha1.StandbyPromoting {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonStandbyPromoting, "stop following %s, promote standby leader %s",
+			status.Source, leader.podName())
+	}
+	if err := cli.PatchConfig(context.Background(), map[string]interface{}{standbyClusterConfigKey: nil}); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonStandbyPromotionFailed, "remove standby_cluster on %s failed: %v", leader.podName(), err)
+		return rollPromote, errors.Wrapf(err, "promote standby cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+
+	if status.Phase != clusterv1alpha1.StandbyPromoting {
+		now := metav1.Now()
+		status.Phase = clusterv1alpha1.StandbyPromoting
+		status.PromotionTime = &now
+	}
+	return rollPromote, nil
+}
+
+// promotionWaiting 开始等待提升时记录事件并进入 Waiting 阶段，之后每次调谐的重复等待只记录日志；
+// 提升过程中 standby leader 短暂不可用同样只记录日志
+func (c *patroniClusterController) promotionWaiting(pCluster *clusterv1alpha1.PatroniCluster, message string) {
+
+	status := pCluster.PatroniClusterStatus.Standby
+	if status.Phase != clusterv1alpha1.StandbyFollowing {
+		klog.V(4).Infof("patroni cluster %s/%s %s: %s", pCluster.Namespace, pCluster.Name, status.Phase, message)
+		return
+	}
+	c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonStandbyPromotionWaiting, message)
+	status.Phase = clusterv1alpha1.StandbyWaiting
+}
+
+// syncStandbyStatus 记录复制源、standby leader 和复制延迟
+func (c *patroniClusterController) syncStandbyStatus(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) {
+
+	status := pCluster.PatroniClusterStatus.Standby
+	if pCluster.PatroniClusterSpec.Standby != nil {
+		if status == nil {
+			status = &clusterv1alpha1.StandbyStatus{Phase: clusterv1alpha1.StandbyFollowing}
+			pCluster.PatroniClusterStatus.Standby = status
+		}
+		// 提升之前重新设置 spec.standby 时继续跟随源集群
+		if status.Phase == clusterv1alpha1.StandbyWaiting {
+			status.Phase = clusterv1alpha1.StandbyFollowing
+		}
+		host, port := standbySource(pCluster)
+		status.Source = fmt.Sprintf("%s:%d", host, port)
+		status.SourceCluster = ""
+		if name := pCluster.PatroniClusterSpec.Standby.ClusterName; name != "" {
+			status.SourceCluster = fmt.Sprintf("%s/%s", standbySourceNamespace(pCluster), name)
+		}
+	}
+	if status == nil {
+		return
+	}
+
+	status.Leader = ""
+	status.Lag = nil
+	for _, m := range members {
+		if m.patroni == nil || !m.patroni.IsStandbyLeader() {
+			continue
+		}
+		status.Leader = m.podName()
+
+		if status.SourceCluster == "" {
+			break
+		}
+		location, err := c.sourceLocation(status.SourceCluster)
+		if err != nil {
+			klog.V(4).Infof("get source location of standby cluster %s/%s failed: %v", pCluster.Namespace, pCluster.Name, err)
+			break
+		}
+		lag := location - m.patroni.Xlog.ReplayedLocation
+		if lag < 0 {
+			lag = 0
+		}
+		status.Lag = &lag
+		break
+	}
+}
+
+// sourceLocation 源集群 leader 当前的 WAL 位置
+func (c *patroniClusterController) sourceLocation(sourceCluster string) (int64, error) {
+
+	ns, name, err := cache.SplitMetaNamespaceKey(sourceCluster)
+	if err != nil {
+		return 0, err
+	}
+	source, err := c.clusterLister.PatroniClusters(ns).Get(name)
+	if err != nil {
+		return 0, err
+	}
+	pods, err := c.kubernetesCli.CoreV1().Pods(ns).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"application":    "patroni",
+			"cluster-name":   name,
+			patroniRoleLabel: leaderLabelValue(source),
+		}).String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP == "" || !isPodReady(pod) {
+			continue
+		}
+		member, err := c.patroniCli(pod.Status.PodIP).Patroni(context.Background())
+		if err != nil {
+			return 0, err
+		}
+		return member.Xlog.Location, nil
+	}
+
+	return 0, fmt.Errorf("source cluster %s has no ready leader", sourceCluster)
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	"strings"
+	"testing"
+)
+
+// TestPromotionWaitingEvents 等待提升时只在进入等待时记录事件
+func TestPromotionWaitingEvents(t *testing.T) {
+
+	pCluster := newTestCluster("a", "b")
+	lag := int64(defaultMaximumLagOnPromotion * 2)
+	pCluster.PatroniClusterStatus.Standby = &clusterv1alpha1.StandbyStatus{Phase: clusterv1alpha1.StandbyFollowing, Lag: &lag}
+	leader := newTestMember(pCluster, "a", newImage, patroni.RoleStandbyLeader)
+	leader.patroni.State = "starting"
+	members := []*clusterMember{leader, newTestMember(pCluster, "b", newImage, patroni.RoleReplica)}
+
+	recorder := record.NewFakeRecorder(10)
+	c := &patroniClusterController{eventRecorder: recorder}
+
+	for i := 0; i < 3; i++ {
+		// 第三次调谐时 standby leader 已经健康，但复制延迟仍然超过阈值
+		if i == 2 {
+			leader.patroni.State = patroni.StateRunning
+		}
+		action, err := c.promoteStandby(pCluster, members)
+		if err != nil || action != rollPromote {
+			t.Fatalf("expected waiting to promote, got %v %v", action, err)
+		}
+	}
+
+	if phase := pCluster.PatroniClusterStatus.Standby.Phase; phase != clusterv1alpha1.StandbyWaiting {
+		t.Errorf("expected phase %s, got %s", clusterv1alpha1.StandbyWaiting, phase)
+	}
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	if len(events) != 1 || !strings.Contains(events[0], reasonStandbyPromotionWaiting) {
+		t.Errorf("expected a single %s event, got %v", reasonStandbyPromotionWaiting, events)
+	}
+
+	// 重新设置 spec.standby 后继续跟随源集群
+	pCluster.PatroniClusterSpec.Standby = &clusterv1alpha1.StandbySpec{Host: "pg-source"}
+	c.syncStandbyStatus(pCluster, members)
+	if phase := pCluster.PatroniClusterStatus.Standby.Phase; phase != clusterv1alpha1.StandbyFollowing {
+		t.Errorf("expected phase %s, got %s", clusterv1alpha1.StandbyFollowing, phase)
+	}
+}
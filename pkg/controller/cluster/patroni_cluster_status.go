@@ -0,0 +1,189 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+)
+
+// 状态条件原因
+const (
+	conditionReasonInitializing       = "Initializing"
+	conditionReasonRollingUpdate      = "RollingUpdate"
+	conditionReasonSwitchover         = "Switchover"
+	conditionReasonScalingIn          = "ScalingIn"
+	conditionReasonScalingOut         = "ScalingOut"
+	conditionReasonVolumeResizing     = "VolumeResizing"
+	conditionReasonPendingMaintenance = "PendingMaintenance"
+	conditionReasonPromoting          = "Promoting"
+	conditionReasonStable             = "Stable"
+	conditionReasonLeaderAvailable    = "LeaderAvailable"
+	conditionReasonNoLeader           = "NoLeader"
+	conditionReasonMembersHealthy     = "MembersHealthy"
+	conditionReasonMembersNotReady    = "MembersNotReady"
+)
+
+// memberRole 不同版本的 Patroni 使用 master 或 primary 表示 leader，与 /cluster 接口统一为 leader
+func memberRole(role string) string {
+	if role == "master" || role == "primary" {
+		return patroni.RoleLeader
+	}
+	return role
+}
+
+// syncClusterStatus 根据成员状态生成集群状态，progressing 为空表示集群没有正在进行的变更，
+// problem 为健康检查发现的异常
+func (c *patroniClusterController) syncClusterStatus(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember, progressing string, problem *healthProblem) {
+
+	status := &pCluster.PatroniClusterStatus
+	status.ObservedGeneration = pCluster.Generation
+
+	topology := c.clusterTopology(members)
+	standby := standbyCluster(pCluster)
+
+	status.Leader = ""
+	status.SyncStandby = nil
+	status.Members = nil
+	ready := 0
+	for _, m := range members {
+		ms := clusterv1alpha1.MemberStatus{
+			Name:  m.podName(),
+			Ready: m.ready(),
+		}
+		if m.pod != nil {
+			ms.Node = m.pod.Spec.NodeName
+		}
+		if m.patroni != nil {
+			ms.State = m.patroni.State
+			ms.Timeline = m.patroni.Timeline
+			ms.PendingRestart = m.patroni.PendingRestart
+			ms.Role = memberRole(m.patroni.Role)
+		}
+		if member, ok := topology[ms.Name]; ok {
+			ms.Role = memberRole(member.Role)
+			ms.State = member.State
+			ms.Timeline = member.Timeline
+			ms.Lag = member.LagBytes()
+			ms.PendingRestart = ms.PendingRestart || member.PendingRestart
+		}
+
+		if ms.Ready {
+			ready++
+		}
+		if m.leader() {
+			status.Leader = ms.Name
+			// 没有 Patroni 状态时根据 Pod 标签判断，备库集群的 leader 为 standby leader
+			if ms.Role == "" {
+				ms.Role = patroni.RoleLeader
+				if standby {
+					ms.Role = patroni.RoleStandbyLeader
+				}
+			}
+		}
+		if ms.Role == patroni.RoleSyncStandby {
+			status.SyncStandby = append(status.SyncStandby, ms.Name)
+		}
+		status.Members = append(status.Members, ms)
+	}
+	status.ReadyMembers = fmt.Sprintf("%d/%d", ready, len(members))
+
+	if status.Leader != "" {
+		setCondition(pCluster, clusterv1alpha1.ConditionAvailable, metav1.ConditionTrue, conditionReasonLeaderAvailable,
+			fmt.Sprintf("leader %s is available", status.Leader))
+	} else {
+		setCondition(pCluster, clusterv1alpha1.ConditionAvailable, metav1.ConditionFalse, conditionReasonNoLeader,
+			"patroni cluster has no leader")
+	}
+
+	if progressing != "" {
+		setCondition(pCluster, clusterv1alpha1.ConditionProgressing, metav1.ConditionTrue, progressing, "")
+	} else {
+		setCondition(pCluster, clusterv1alpha1.ConditionProgressing, metav1.ConditionFalse, conditionReasonStable, "")
+	}
+
+	if problem != nil {
+		setCondition(pCluster, clusterv1alpha1.ConditionDegraded, metav1.ConditionTrue, problem.reason, problem.message)
+	} else if ready != len(members) {
+		setCondition(pCluster, clusterv1alpha1.ConditionDegraded, metav1.ConditionTrue, conditionReasonMembersNotReady,
+			fmt.Sprintf("%d of %d members are not ready", len(members)-ready, len(members)))
+	} else {
+		setCondition(pCluster, clusterv1alpha1.ConditionDegraded, metav1.ConditionFalse, conditionReasonMembersHealthy, "")
+	}
+}
+
+// clusterTopology 从 leader（没有 leader 时从任意成员）获取 Patroni 视角下的成员信息
+func (c *patroniClusterController) clusterTopology(members []*clusterMember) map[string]patroni.Member {
+
+	var sources []*clusterMember
+	for _, m := range members {
+		if m.pod == nil || m.pod.Status.PodIP == "" || m.patroni == nil {
+			continue
+		}
+		if m.leader() {
+			sources = append([]*clusterMember{m}, sources...)
+		} else {
+			sources = append(sources, m)
+		}
+	}
+
+	topology := map[string]patroni.Member{}
+	for _, m := range sources {
+		info, err := c.patroniCli(m.pod.Status.PodIP).Cluster(context.Background())
+		if err != nil {
+			klog.V(4).Infof("query patroni cluster from %s/%s failed: %v", m.pod.Namespace, m.podName(), err)
+			continue
+		}
+		for _, member := range info.Members {
+			topology[member.Name] = member
+		}
+		break
+	}
+
+	return topology
+}
+
+// setCondition 只在条件状态变化时更新 LastTransitionTime
+func setCondition(pCluster *clusterv1alpha1.PatroniCluster, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&pCluster.PatroniClusterStatus.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: pCluster.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// updateClusterStatus 集群状态发生变化时通过 status 子资源写回 apiserver
+func (c *patroniClusterController) updateClusterStatus(pCluster *clusterv1alpha1.PatroniCluster, oldStatus *clusterv1alpha1.PatroniClusterStatus) error {
+
+	if equality.Semantic.DeepEqual(oldStatus, &pCluster.PatroniClusterStatus) {
+		return nil
+	}
+
+	_, err := c.updateStatus(pCluster)
+	return err
+}
+
+// updateStatus 通过 status 子资源更新集群状态，CRD 开启子资源后 Update 会忽略状态字段
+func (c *patroniClusterController) updateStatus(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+	return c.statusWriter.UpdateClusterStatus(pCluster)
+}
+
+// patchSpec 以 merge patch 只更新 spec 中指定的字段，值为 nil 的字段会被删除，
+// 避免将调谐过程中写入缓存对象的默认值持久化到用户的 spec 中
+func (c *patroniClusterController) patchSpec(pCluster *clusterv1alpha1.PatroniCluster, spec map[string]interface{}) (*clusterv1alpha1.PatroniCluster, error) {
+
+	data, err := json.Marshal(map[string]interface{}{"spec": spec})
+	if err != nil {
+		return nil, err
+	}
+	return c.pgOperatorCli.RccpV1alpha1().PatroniClusters(pCluster.Namespace).Patch(context.Background(), pCluster.Name,
+		types.MergePatchType, data, metav1.PatchOptions{})
+}
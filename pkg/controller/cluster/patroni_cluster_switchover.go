@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"time"
+)
+
+// 事件类型
+const (
+	reasonFailover          = "Failover"
+	reasonFailoverFailed    = "FailoverFailed"
+	reasonSwitchoverInvalid = "SwitchoverInvalid"
+)
+
+const (
+	switchoverTypeSwitchover = "switchover"
+	switchoverTypeFailover   = "failover"
+	switchoverSucceeded      = "Succeeded"
+	switchoverFailed         = "Failed"
+)
+
+// manualSwitchover 执行 spec.switchover 请求，执行后无论成功与否都清除请求并记录结果，
+// 返回清除请求后的集群对象
+func (c *patroniClusterController) manualSwitchover(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember) (*clusterv1alpha1.PatroniCluster, rollAction, error) {
+
+	req := pCluster.PatroniClusterSpec.Switchover
+	if req == nil {
+		return pCluster, rollNone, nil
+	}
+
+	now := metav1.Now()
+	if req.ScheduledAt != nil && now.Before(req.ScheduledAt) {
+		klog.V(4).Infof("switchover of patroni cluster %s/%s scheduled at %s", pCluster.Namespace, pCluster.Name, req.ScheduledAt.String())
+		return pCluster, rollNone, nil
+	}
+
+	result := &clusterv1alpha1.SwitchoverStatus{
+		Type: switchoverTypeSwitchover,
+		Time: now,
+	}
+	if req.Failover {
+		result.Type = switchoverTypeFailover
+	}
+
+	var leader *clusterMember
+	for _, m := range members {
+		if m.leader() {
+			leader = m
+			break
+		}
+	}
+	if leader != nil {
+		result.From = leader.podName()
+	}
+
+	action := rollSwitchover
+	candidate, err := c.switchoverTarget(req, leader, members)
+	if err == nil && candidate.leader() {
+		// 上次切换成功后清除请求失败，重试时目标已经是 leader
+		action = rollNone
+		result.To = candidate.podName()
+		result.From = ""
+		klog.V(2).Infof("patroni member %s/%s is already the leader, %s succeeded", pCluster.Namespace, candidate.podName(), result.Type)
+	} else if err == nil {
+		result.To = candidate.podName()
+		if req.Failover {
+			err = c.failover(pCluster, candidate)
+		} else {
+			err = c.switchover(pCluster, leader, candidate)
+		}
+	} else {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonSwitchoverInvalid, "reject %s request: %v", result.Type, err)
+	}
+
+	if err != nil {
+		action = rollNone
+		result.Result = switchoverFailed
+		result.Message = err.Error()
+	} else {
+		result.Result = switchoverSucceeded
+	}
+
+	updated, err := c.patchSpec(pCluster, map[string]interface{}{"switchover": nil})
+	if err != nil {
+		return pCluster, rollNone, errors.Wrapf(err, "clear switchover request of patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+	// patch 返回的对象带有服务端的最新状态，其中可能有定时备份控制器等写入的字段，
+	// 只保留本次调谐在切换之前计算的备库状态和切换结果
+	updated.PatroniClusterStatus.Standby = pCluster.PatroniClusterStatus.Standby
+	updated.PatroniClusterStatus.LastSwitchover = result
+
+	return updated, action, nil
+}
+
+// switchoverRequeueAfter 到计划切换时间的间隔，不超过 limit
+func switchoverRequeueAfter(pCluster *clusterv1alpha1.PatroniCluster, limit time.Duration) time.Duration {
+	req := pCluster.PatroniClusterSpec.Switchover
+	if req == nil || req.ScheduledAt == nil {
+		return limit
+	}
+	if d := time.Until(req.ScheduledAt.Time); d > 0 && d < limit {
+		return d
+	}
+	return limit
+}
+
+// switchoverTarget 校验并选择切换目标
+func (c *patroniClusterController) switchoverTarget(req *clusterv1alpha1.SwitchoverSpec, leader *clusterMember, members []*clusterMember) (*clusterMember, error) {
+
+	// 指定的目标已经是 leader 时视为切换已经完成
+	if req.Candidate != "" && leader != nil && (leader.name == req.Candidate || leader.podName() == req.Candidate) {
+		return leader, nil
+	}
+
+	if !req.Failover && (leader == nil || !leader.healthy()) {
+		return nil, fmt.Errorf("leader is not healthy, use failover instead")
+	}
+
+	if req.Candidate == "" {
+		for _, m := range members {
+			if !m.leader() && m.healthy() {
+				return m, nil
+			}
+		}
+		return nil, fmt.Errorf("no healthy replica available")
+	}
+
+	for _, m := range members {
+		if m.name != req.Candidate && m.podName() != req.Candidate {
+			continue
+		}
+		if !m.healthy() {
+			return nil, fmt.Errorf("member %s is not healthy", m.podName())
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("member %s not found", req.Candidate)
+}
+
+func (c *patroniClusterController) failover(pCluster *clusterv1alpha1.PatroniCluster, candidate *clusterMember) error {
+
+	cli := c.patroniCli(candidate.pod.Status.PodIP)
+	if err := cli.Failover(context.Background(), candidate.podName()); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonFailoverFailed, "failover to %s failed: %v", candidate.podName(), err)
+		return errors.Wrapf(err, "failover patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonFailover, "failover to %s", candidate.podName())
+	return nil
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	"testing"
+)
+
+// TestSwitchoverKeepsServerStatus 清除切换请求后保留其他控制器在本次调谐期间写入的状态
+func TestSwitchoverKeepsServerStatus(t *testing.T) {
+
+	client := newFakePgOperatorCli()
+	pCluster := newTestCluster("a", "b")
+	pCluster.PatroniClusterSpec.Switchover = &clusterv1alpha1.SwitchoverSpec{Candidate: "a"}
+	pCluster, err := client.RccpV1alpha1().PatroniClusters("default").Create(context.Background(), pCluster, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create patroni cluster failed: %v", err)
+	}
+	c := &patroniClusterController{
+		pgOperatorCli: client,
+		statusWriter:  &fakeStatusWriter{client: client},
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+
+	// 定时备份控制器在调谐期间更新了状态
+	scheduled := pCluster.DeepCopy()
+	scheduled.PatroniClusterStatus.BackupSchedules = []clusterv1alpha1.BackupScheduleStatus{{Name: "daily", LastBackup: "pg-daily-1"}}
+	if _, err := c.updateStatus(scheduled); err != nil {
+		t.Fatalf("update patroni cluster status failed: %v", err)
+	}
+
+	members := []*clusterMember{
+		newTestMember(pCluster, "a", newImage, "master"),
+		newTestMember(pCluster, "b", newImage, patroni.RoleReplica),
+	}
+	updated, action, err := c.manualSwitchover(pCluster, members)
+	if err != nil || action != rollNone {
+		t.Fatalf("expected switchover already done, got %v %v", action, err)
+	}
+
+	status := updated.PatroniClusterStatus
+	if updated.PatroniClusterSpec.Switchover != nil {
+		t.Errorf("expected switchover request cleared")
+	}
+	if status.LastSwitchover == nil || status.LastSwitchover.Result != switchoverSucceeded || status.LastSwitchover.To != "pg-a-0" {
+		t.Errorf("expected succeeded switchover to pg-a-0, got %+v", status.LastSwitchover)
+	}
+	if len(status.BackupSchedules) != 1 || status.BackupSchedules[0].LastBackup != "pg-daily-1" {
+		t.Errorf("expected backup schedule status kept, got %+v", status.BackupSchedules)
+	}
+}
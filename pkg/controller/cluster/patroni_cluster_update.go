@@ -0,0 +1,428 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"time"
+)
+
+// 事件类型
+const (
+	reasonMemberUpdating   = "MemberUpdating"
+	reasonUpdateFailed     = "UpdateFailed"
+	reasonSwitchover       = "Switchover"
+	reasonSwitchoverFailed = "SwitchoverFailed"
+	reasonUpgradeStarted   = "UpgradeStarted"
+	reasonUpgradeCompleted = "UpgradeCompleted"
+)
+
+// Patroni 通过 kubernetes DCS 为 Pod 设置的角色标签
+const patroniRoleLabel = "role"
+
+// 备库集群中 standby leader 承担 leader 的角色
+var patroniLeaderRoles = sets.NewString("master", "primary", "standby_leader")
+
+// rollAction 一次调谐中执行的滚动动作
+type rollAction int
+
+const (
+	rollNone rollAction = iota
+	rollWaiting
+	rollReplica
+	rollSwitchover
+	rollLeader
+	rollScaleIn
+	rollScaleOut
+	rollResize
+	// 不在维护窗口内，推迟滚动更新或切换
+	rollPending
+	// 备库集群正在提升为主集群
+	rollPromote
+)
+
+// clusterMember 集群成员的期望状态和线上状态
+type clusterMember struct {
+	name    string
+	desired appsv1.StatefulSet
+	live    *appsv1.StatefulSet
+	pod     *v1.Pod
+	diff    []string
+	// 通过 Patroni REST API 获取的成员状态，获取失败时为空
+	patroni *patroni.MemberStatus
+}
+
+// ready 成员 Pod 就绪并且 statefulset 没有未完成的滚动更新
+func (m *clusterMember) ready() bool {
+	if m.live == nil || m.pod == nil || !isPodReady(m.pod) {
+		return false
+	}
+	status := m.live.Status
+	return status.ObservedGeneration >= m.live.Generation && status.CurrentRevision == status.UpdateRevision
+}
+
+// leader 优先使用 Patroni REST API 返回的角色，无法访问时使用 Pod 角色标签，备库集群中为 standby leader
+func (m *clusterMember) leader() bool {
+	if m.patroni != nil {
+		return m.patroni.IsLeader() || m.patroni.IsStandbyLeader()
+	}
+	return m.pod != nil && patroniLeaderRoles.Has(m.pod.Labels[patroniRoleLabel])
+}
+
+// healthy 成员就绪并且 Patroni 处于 running 状态
+func (m *clusterMember) healthy() bool {
+	return m.ready() && m.patroni != nil && m.patroni.State == patroni.StateRunning
+}
+
+// runningImage 成员 Pod 当前使用的镜像
+func (m *clusterMember) runningImage() string {
+	if m.pod == nil {
+		return ""
+	}
+	for _, container := range m.pod.Spec.Containers {
+		if container.Name == postgresContainerName {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+func (m *clusterMember) podName() string {
+	return fmt.Sprintf("%s-0", m.desired.Name)
+}
+
+// listMembers 获取 NodeList 中所有成员的状态
+func (c *patroniClusterController) listMembers(pCluster *clusterv1alpha1.PatroniCluster) ([]*clusterMember, error) {
+
+	ns := pCluster.Namespace
+	var members []*clusterMember
+	for _, n := range pCluster.PatroniClusterSpec.NodeList {
+		m := &clusterMember{
+			name:    n,
+			desired: generatorStatefulset(n, pCluster),
+		}
+
+		live, err := c.kubernetesCli.AppsV1().StatefulSets(ns).Get(context.Background(), m.desired.Name, metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			m.live = live
+			m.diff = templateDiff(live, &m.desired)
+		}
+
+		if err := c.loadMemberState(ns, m); err != nil {
+			return nil, err
+		}
+
+		members = append(members, m)
+	}
+
+	return members, nil
+}
+
+// loadMemberState 获取成员 Pod 和 Patroni 状态
+func (c *patroniClusterController) loadMemberState(ns string, m *clusterMember) error {
+
+	pod, err := c.kubernetesCli.CoreV1().Pods(ns).Get(context.Background(), m.podName(), metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		m.pod = pod
+	}
+
+	if m.pod != nil && m.pod.Status.PodIP != "" {
+		status, err := c.patroniCli(m.pod.Status.PodIP).Patroni(context.Background())
+		if err != nil {
+			klog.V(4).Infof("query patroni member %s/%s failed: %v", ns, m.podName(), err)
+		} else {
+			m.patroni = status
+		}
+	}
+
+	return nil
+}
+
+// updateCluster 比较成员期望的 statefulset 和线上状态，滚动更新成员并记录镜像升级进度
+func (c *patroniClusterController) updateCluster(pCluster *clusterv1alpha1.PatroniCluster) (ctrl.Result, error) {
+
+	oldStatus := pCluster.PatroniClusterStatus.DeepCopy()
+
+	if err := c.initCluster(pCluster); err != nil {
+		klog.Error(errors.Wrapf(err, "sync patroni cluster %s/%s members failed", pCluster.Namespace, pCluster.Name))
+		return ctrl.Result{}, err
+	}
+
+	members, err := c.listMembers(pCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	window, err := maintenanceWindow(pCluster, time.Now())
+	if err != nil {
+		// 维护窗口配置错误时不执行任何破坏性操作
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonInvalidMaintenanceWindow, err.Error())
+		window = &maintenanceState{}
+	}
+
+	// 备库集群提升优先，其次是手动切换和缩容，缩容完成后再滚动更新
+	c.syncStandbyStatus(pCluster, members)
+	action, err := c.promoteStandby(pCluster, members)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if action == rollNone {
+		pCluster, action, err = c.manualSwitchover(pCluster, members)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if action == rollNone {
+		action, err = c.scaleIn(pCluster, members, window)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if action == rollNone {
+		action, err = c.rollMembers(pCluster, members, window)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if action == rollNone && scalingOut(pCluster, members) {
+		action = rollScaleOut
+	}
+
+	// 数据卷扩容不影响成员运行，与其他变更同时进行
+	resizing, err := c.expandVolumes(pCluster, members)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if action == rollNone && resizing {
+		action = rollResize
+	}
+
+	if err := c.syncDynamicConfig(pCluster, members); err != nil {
+		klog.Error(errors.Wrapf(err, "sync patroni cluster %s/%s dynamic config failed", pCluster.Namespace, pCluster.Name))
+		c.eventRecorder.Event(pCluster, v1.EventTypeWarning, reasonConfigFailed, err.Error())
+	}
+
+	syncMaintenanceStatus(pCluster, window)
+
+	// 修正早期版本写入的 Runing 状态值
+	pCluster.PatroniClusterStatus.Status = clusterv1alpha1.ClusterRunning
+	c.syncUpgradeStatus(pCluster, members, action)
+	problem := c.checkClusterHealth(pCluster, members)
+	c.syncClusterStatus(pCluster, members, progressingReason(action), problem)
+
+	if err := c.updateClusterStatus(pCluster, oldStatus); err != nil {
+		klog.Error(errors.Wrapf(err, "update patroni cluster %s/%s status failed", pCluster.Namespace, pCluster.Name))
+		return ctrl.Result{}, err
+	}
+
+	// 没有进行中的变更时按照健康检查间隔重新调谐，等待维护窗口或计划切换时在窗口打开或到达切换时间时重新调谐
+	switch action {
+	case rollNone:
+		return ctrl.Result{RequeueAfter: switchoverRequeueAfter(pCluster, c.healthCheckInterval)}, nil
+	case rollPending:
+		return ctrl.Result{RequeueAfter: switchoverRequeueAfter(pCluster, window.requeueAfter(c.healthCheckInterval))}, nil
+	}
+	return ctrl.Result{RequeueAfter: c.waitPeriod}, nil
+}
+
+func progressingReason(action rollAction) string {
+	switch action {
+	case rollWaiting, rollReplica, rollLeader:
+		return conditionReasonRollingUpdate
+	case rollSwitchover:
+		return conditionReasonSwitchover
+	case rollScaleIn:
+		return conditionReasonScalingIn
+	case rollScaleOut:
+		return conditionReasonScalingOut
+	case rollResize:
+		return conditionReasonVolumeResizing
+	case rollPending:
+		return conditionReasonPendingMaintenance
+	case rollPromote:
+		return conditionReasonPromoting
+	}
+	return ""
+}
+
+// rollMembers 每次调谐最多滚动一个成员：
+// 先更新 replica，最后将 leader 切换到已更新的健康 replica 后再更新原 leader
+func (c *patroniClusterController) rollMembers(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember, window *maintenanceState) (rollAction, error) {
+
+	var outdated []*clusterMember
+	var names []string
+	for _, m := range members {
+		if m.live != nil && len(m.diff) != 0 {
+			outdated = append(outdated, m)
+			names = append(names, m.podName())
+		}
+	}
+	if len(outdated) == 0 {
+		return rollNone, nil
+	}
+
+	if window.deferred(fmt.Sprintf("rolling update of %s", strings.Join(names, ", "))) {
+		return rollPending, nil
+	}
+
+	// 有成员未就绪时不做变更，等待上一个成员完成更新
+	for _, m := range members {
+		if !m.ready() {
+			klog.V(4).Infof("patroni member %s/%s not ready, waiting...", pCluster.Namespace, m.desired.Name)
+			return rollWaiting, nil
+		}
+	}
+
+	action := rollReplica
+	var target *clusterMember
+	for _, m := range outdated {
+		if !m.leader() {
+			target = m
+			break
+		}
+	}
+
+	// 只剩 leader 需要更新，先切换到已更新的 replica
+	if target == nil {
+		leader := outdated[0]
+		candidate := switchoverCandidate(members)
+		if candidate != nil {
+			if err := c.switchover(pCluster, leader, candidate); err != nil {
+				return rollNone, err
+			}
+			return rollSwitchover, nil
+		}
+		// 多成员集群没有可切换的 replica 时等待，避免直接重启 leader 触发故障转移
+		if len(members) > 1 {
+			klog.V(4).Infof("no healthy replica of patroni cluster %s/%s to switchover, waiting...", pCluster.Namespace, pCluster.Name)
+			return rollWaiting, nil
+		}
+		// 单成员集群直接更新
+		action = rollLeader
+		target = leader
+	}
+
+	if err := c.updateMember(pCluster, target); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonUpdateFailed, "update patroni member %s failed: %v", target.desired.Name, err)
+		return rollNone, err
+	}
+
+	return action, nil
+}
+
+// syncUpgradeStatus 根据成员运行的镜像记录升级进度
+func (c *patroniClusterController) syncUpgradeStatus(pCluster *clusterv1alpha1.PatroniCluster, members []*clusterMember, action rollAction) {
+
+	targetImage := pCluster.PatroniClusterSpec.Image
+
+	var upgraded []string
+	for _, m := range members {
+		if m.ready() && m.runningImage() == targetImage {
+			upgraded = append(upgraded, m.podName())
+		}
+	}
+
+	upgrade := pCluster.PatroniClusterStatus.Upgrade
+	if upgrade != nil && upgrade.TargetImage == targetImage {
+		// 升级已经完成，之后的滚动更新不属于本次升级
+		if upgrade.Phase == clusterv1alpha1.UpgradeCompleted {
+			return
+		}
+		upgrade = upgrade.DeepCopy()
+	} else {
+		// 所有成员已经运行目标镜像，不需要升级
+		if len(upgraded) == len(members) {
+			return
+		}
+		now := metav1.Now()
+		upgrade = &clusterv1alpha1.UpgradeStatus{
+			Phase:       clusterv1alpha1.UpgradeReplicas,
+			TargetImage: targetImage,
+			StartTime:   &now,
+		}
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonUpgradeStarted, "start upgrading patroni cluster to image %s", targetImage)
+	}
+
+	upgrade.UpgradedMembers = upgraded
+	upgrade.Progress = fmt.Sprintf("%d/%d", len(upgraded), len(members))
+
+	switch action {
+	case rollReplica:
+		// 切换完成后原 leader 已经成为 replica
+		if upgrade.Phase == clusterv1alpha1.UpgradeSwitchover {
+			upgrade.Phase = clusterv1alpha1.UpgradeLeader
+		} else {
+			upgrade.Phase = clusterv1alpha1.UpgradeReplicas
+		}
+	case rollSwitchover:
+		upgrade.Phase = clusterv1alpha1.UpgradeSwitchover
+	case rollLeader:
+		upgrade.Phase = clusterv1alpha1.UpgradeLeader
+	case rollNone:
+		if len(upgraded) == len(members) {
+			now := metav1.Now()
+			upgrade.Phase = clusterv1alpha1.UpgradeCompleted
+			upgrade.CompletionTime = &now
+			c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonUpgradeCompleted, "patroni cluster upgraded to image %s", targetImage)
+		}
+	}
+
+	pCluster.PatroniClusterStatus.Upgrade = upgrade
+}
+
+// switchoverCandidate 选择已经更新完成的健康 replica 作为切换目标
+func switchoverCandidate(members []*clusterMember) *clusterMember {
+	for _, m := range members {
+		if !m.leader() && len(m.diff) == 0 && m.healthy() {
+			return m
+		}
+	}
+	return nil
+}
+
+func (c *patroniClusterController) switchover(pCluster *clusterv1alpha1.PatroniCluster, leader, candidate *clusterMember) error {
+
+	cli := c.patroniCli(leader.pod.Status.PodIP)
+	if err := cli.Switchover(context.Background(), leader.podName(), candidate.podName()); err != nil {
+		c.eventRecorder.Eventf(pCluster, v1.EventTypeWarning, reasonSwitchoverFailed, "switchover from %s to %s failed: %v", leader.podName(), candidate.podName(), err)
+		return errors.Wrapf(err, "switchover patroni cluster %s/%s failed", pCluster.Namespace, pCluster.Name)
+	}
+
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonSwitchover, "switchover from %s to %s", leader.podName(), candidate.podName())
+	return nil
+}
+
+// updateMember 将成员 statefulset 的 Pod 模板更新为期望值，由 statefulset 控制器重建 Pod
+func (c *patroniClusterController) updateMember(pCluster *clusterv1alpha1.PatroniCluster, m *clusterMember) error {
+
+	sts := m.live.DeepCopy()
+	sts.Spec.Template = m.desired.Spec.Template
+	if sts.Annotations == nil {
+		sts.Annotations = map[string]string{}
+	}
+	sts.Annotations[lastAppliedTemplateAnnotation] = m.desired.Annotations[lastAppliedTemplateAnnotation]
+
+	if _, err := c.kubernetesCli.AppsV1().StatefulSets(sts.Namespace).Update(context.Background(), sts, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("rolling patroni member %s/%s, diff: %s", sts.Namespace, sts.Name, strings.Join(m.diff, "; "))
+	c.eventRecorder.Eventf(pCluster, v1.EventTypeNormal, reasonMemberUpdating, "rolling patroni member %s", sts.Name)
+	return nil
+}
@@ -0,0 +1,244 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/simple/client/patroni"
+	"testing"
+)
+
+const (
+	oldImage = "patroni:2.1"
+	newImage = "patroni:3.0"
+)
+
+// fakePatroniCli 记录控制器通过 Patroni REST API 发起的切换
+type fakePatroniCli struct {
+	patroni.Interface
+	switchovers *[]string
+}
+
+func (f *fakePatroniCli) Switchover(ctx context.Context, leader, candidate string) error {
+	*f.switchovers = append(*f.switchovers, fmt.Sprintf("%s->%s", leader, candidate))
+	return nil
+}
+
+func newTestController(objs ...*appsv1.StatefulSet) (*patroniClusterController, *[]string) {
+
+	kubernetesCli := fake.NewSimpleClientset()
+	for _, sts := range objs {
+		kubernetesCli.AppsV1().StatefulSets(sts.Namespace).Create(context.Background(), sts, metav1.CreateOptions{})
+	}
+
+	switchovers := &[]string{}
+	return &patroniClusterController{
+		kubernetesCli: kubernetesCli,
+		eventRecorder: record.NewFakeRecorder(100),
+		patroniCli: func(host string) patroni.Interface {
+			return &fakePatroniCli{switchovers: switchovers}
+		},
+	}, switchovers
+}
+
+func newTestCluster(nodes ...string) *clusterv1alpha1.PatroniCluster {
+	pCluster := &clusterv1alpha1.PatroniCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "pg", Namespace: "default"},
+	}
+	pCluster.PatroniClusterSpec.Image = newImage
+	pCluster.PatroniClusterSpec.NodeList = nodes
+	return pCluster
+}
+
+// newTestMember 运行 image 镜像的就绪成员，镜像与集群期望不一致时模板存在差异
+func newTestMember(pCluster *clusterv1alpha1.PatroniCluster, name, image, role string) *clusterMember {
+
+	stsName := fmt.Sprintf("%s-%s", pCluster.Name, name)
+	m := &clusterMember{
+		name: name,
+		desired: appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: stsName, Namespace: pCluster.Namespace},
+		},
+		live: &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: stsName, Namespace: pCluster.Namespace, Generation: 1},
+			Status: appsv1.StatefulSetStatus{
+				ObservedGeneration: 1,
+				CurrentRevision:    "r1",
+				UpdateRevision:     "r1",
+			},
+		},
+		pod: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: stsName + "-0", Namespace: pCluster.Namespace},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: postgresContainerName, Image: image}},
+			},
+			Status: v1.PodStatus{
+				PodIP:      "10.0.0.1",
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		},
+		patroni: &patroni.MemberStatus{State: patroni.StateRunning, Role: role},
+	}
+	if image != pCluster.PatroniClusterSpec.Image {
+		m.diff = []string{fmt.Sprintf("image: %s -> %s", image, pCluster.PatroniClusterSpec.Image)}
+	}
+	return m
+}
+
+// upgraded 模拟 statefulset 控制器以新镜像重建成员 Pod
+func upgraded(m *clusterMember, image string) {
+	m.diff = nil
+	m.pod.Spec.Containers[0].Image = image
+}
+
+func lastUpdated(t *testing.T, c *patroniClusterController, ns string) []string {
+	list, err := c.kubernetesCli.AppsV1().StatefulSets(ns).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list statefulsets failed: %v", err)
+	}
+	var names []string
+	for _, sts := range list.Items {
+		if _, ok := sts.Annotations[lastAppliedTemplateAnnotation]; ok {
+			names = append(names, sts.Name)
+		}
+	}
+	return names
+}
+
+// TestRollMembersUpgrade 升级按照 replica、切换、原 leader 的顺序进行，每次调谐只变更一个成员
+func TestRollMembersUpgrade(t *testing.T) {
+
+	pCluster := newTestCluster("a", "b", "c")
+	a := newTestMember(pCluster, "a", oldImage, "master")
+	b := newTestMember(pCluster, "b", oldImage, patroni.RoleReplica)
+	cm := newTestMember(pCluster, "c", oldImage, patroni.RoleReplica)
+	members := []*clusterMember{a, b, cm}
+
+	c, switchovers := newTestController(a.live, b.live, cm.live)
+	window := &maintenanceState{open: true}
+
+	steps := []struct {
+		action  rollAction
+		phase   clusterv1alpha1.UpgradePhase
+		updated []string
+		// 本次调谐之后成员状态的变化
+		after func()
+	}{
+		{rollReplica, clusterv1alpha1.UpgradeReplicas, []string{"pg-b"}, func() { upgraded(b, newImage) }},
+		{rollReplica, clusterv1alpha1.UpgradeReplicas, []string{"pg-b", "pg-c"}, func() { upgraded(cm, newImage) }},
+		{rollSwitchover, clusterv1alpha1.UpgradeSwitchover, []string{"pg-b", "pg-c"}, func() {
+			a.patroni.Role, b.patroni.Role = patroni.RoleReplica, "master"
+		}},
+		{rollReplica, clusterv1alpha1.UpgradeLeader, []string{"pg-a", "pg-b", "pg-c"}, func() { upgraded(a, newImage) }},
+		{rollNone, clusterv1alpha1.UpgradeCompleted, []string{"pg-a", "pg-b", "pg-c"}, func() {}},
+	}
+
+	for i, step := range steps {
+		action, err := c.rollMembers(pCluster, members, window)
+		if err != nil {
+			t.Fatalf("step %d: roll members failed: %v", i, err)
+		}
+		if action != step.action {
+			t.Fatalf("step %d: expected action %v, got %v", i, step.action, action)
+		}
+
+		c.syncUpgradeStatus(pCluster, members, action)
+		upgrade := pCluster.PatroniClusterStatus.Upgrade
+		if upgrade == nil || upgrade.Phase != step.phase {
+			t.Fatalf("step %d: expected upgrade phase %s, got %+v", i, step.phase, upgrade)
+		}
+		if updated := lastUpdated(t, c, pCluster.Namespace); fmt.Sprint(updated) != fmt.Sprint(step.updated) {
+			t.Fatalf("step %d: expected updated members %v, got %v", i, step.updated, updated)
+		}
+		step.after()
+	}
+
+	if fmt.Sprint(*switchovers) != "[pg-a-0->pg-b-0]" {
+		t.Errorf("expected a single switchover from pg-a-0 to pg-b-0, got %v", *switchovers)
+	}
+	if upgrade := pCluster.PatroniClusterStatus.Upgrade; upgrade.Progress != "3/3" || upgrade.CompletionTime == nil {
+		t.Errorf("expected completed upgrade, got %+v", upgrade)
+	}
+}
+
+// TestRollMembersNoCandidate 没有健康的已更新 replica 时不重启 leader
+func TestRollMembersNoCandidate(t *testing.T) {
+
+	pCluster := newTestCluster("a", "b")
+	a := newTestMember(pCluster, "a", oldImage, "master")
+	b := newTestMember(pCluster, "b", newImage, patroni.RoleReplica)
+	// replica 的 Patroni REST API 无法访问
+	b.patroni = nil
+	members := []*clusterMember{a, b}
+
+	c, switchovers := newTestController(a.live, b.live)
+
+	action, err := c.rollMembers(pCluster, members, &maintenanceState{open: true})
+	if err != nil {
+		t.Fatalf("roll members failed: %v", err)
+	}
+	if action != rollWaiting {
+		t.Errorf("expected rollWaiting, got %v", action)
+	}
+	if updated := lastUpdated(t, c, pCluster.Namespace); len(updated) != 0 {
+		t.Errorf("expected no member updated, got %v", updated)
+	}
+	if len(*switchovers) != 0 {
+		t.Errorf("expected no switchover, got %v", *switchovers)
+	}
+}
+
+// TestRollMembersSingleLeader 单成员集群直接更新 leader
+func TestRollMembersSingleLeader(t *testing.T) {
+
+	pCluster := newTestCluster("a")
+	a := newTestMember(pCluster, "a", oldImage, "master")
+	members := []*clusterMember{a}
+
+	c, switchovers := newTestController(a.live)
+
+	action, err := c.rollMembers(pCluster, members, &maintenanceState{open: true})
+	if err != nil {
+		t.Fatalf("roll members failed: %v", err)
+	}
+	if action != rollLeader {
+		t.Errorf("expected rollLeader, got %v", action)
+	}
+	c.syncUpgradeStatus(pCluster, members, action)
+	if phase := pCluster.PatroniClusterStatus.Upgrade.Phase; phase != clusterv1alpha1.UpgradeLeader {
+		t.Errorf("expected upgrade phase %s, got %s", clusterv1alpha1.UpgradeLeader, phase)
+	}
+	if updated := lastUpdated(t, c, pCluster.Namespace); fmt.Sprint(updated) != "[pg-a]" {
+		t.Errorf("expected pg-a updated, got %v", updated)
+	}
+	if len(*switchovers) != 0 {
+		t.Errorf("expected no switchover, got %v", *switchovers)
+	}
+}
+
+// TestRollMembersMaintenanceWindow 窗口关闭时推迟滚动更新
+func TestRollMembersMaintenanceWindow(t *testing.T) {
+
+	pCluster := newTestCluster("a", "b")
+	a := newTestMember(pCluster, "a", oldImage, "master")
+	b := newTestMember(pCluster, "b", oldImage, patroni.RoleReplica)
+
+	c, _ := newTestController(a.live, b.live)
+	window := &maintenanceState{}
+
+	action, err := c.rollMembers(pCluster, []*clusterMember{a, b}, window)
+	if err != nil {
+		t.Fatalf("roll members failed: %v", err)
+	}
+	if action != rollPending || len(window.pending) != 1 {
+		t.Errorf("expected pending rolling update, got %v %v", action, window.pending)
+	}
+	if updated := lastUpdated(t, c, pCluster.Namespace); len(updated) != 0 {
+		t.Errorf("expected no member updated, got %v", updated)
+	}
+}
@@ -0,0 +1,60 @@
+package cluster
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"strings"
+)
+
+// podExecFunc 在成员的 postgres 容器中执行命令，stdin 用于传递敏感内容，避免出现在进程参数中，
+// 集群控制器通过它修改密码，备份控制器通过它调用 wal-g
+type podExecFunc func(pod *v1.Pod, command []string, stdin io.Reader) (string, error)
+
+func newPodExecFunc(kubernetesCli kubernetes.Interface, restConfig *rest.Config) podExecFunc {
+	return func(pod *v1.Pod, command []string, stdin io.Reader) (string, error) {
+		return podExec(kubernetesCli, restConfig, pod, command, stdin)
+	}
+}
+
+func podExec(kubernetesCli kubernetes.Interface, restConfig *rest.Config, pod *v1.Pod, command []string, stdin io.Reader) (string, error) {
+
+	if restConfig == nil {
+		return "", errors.New("kubernetes rest config is not provided")
+	}
+
+	req := kubernetesCli.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: postgresContainerName,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(restConfig, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return stdout.String(), errors.Wrapf(err, "exec in pod %s/%s failed: %s", pod.Namespace, pod.Name, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"encoding/json"
 	"fmt"
 	v1 "k8s.io/api/apps/v1"
 	coreV1 "k8s.io/api/core/v1"
@@ -8,6 +9,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"pgoperator/pkg/apis/cluster/v1alpha1"
+	"pgoperator/pkg/utils/reflectutils"
+)
+
+const (
+	lastAppliedTemplateAnnotation = "rccp.ruijie.com.cn/last-applied-template"
+	credentialsHashAnnotation     = "rccp.ruijie.com.cn/credentials-hash"
+	postgresContainerName         = "postgres"
+	defaultVolumeSize             = "5Gi"
 )
 
 func affinitySet(pClusterName string, require bool) coreV1.PodAntiAffinity {
@@ -61,15 +70,56 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 	}
 
 	podAffinitySet := affinitySet(pClusterName, pCluster.PatroniClusterSpec.RequirePodAntiAffinity)
+	member := memberSpec(pCluster, indexName)
+	var nodeSelector map[string]string
+	if member != nil {
+		nodeSelector = member.NodeSelector
+	}
+
+	serviceAccount := pCluster.PatroniClusterSpec.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = defaultServiceAccountName
+	}
 
-	if pCluster.PatroniClusterSpec.ServiceAccount == "" {
-		pCluster.PatroniClusterSpec.ServiceAccount = defaultServiceAccountName
+	// 密码摘要变化时 Pod 模板随之变化，触发成员滚动重启以加载新密码
+	// Patroni 本地配置变化时同样需要重启成员
+	podAnnotations := map[string]string{
+		patroniConfigAnnotation: patroniConfigHash(pCluster),
+	}
+	if credentials := pCluster.PatroniClusterStatus.Credentials; credentials != nil {
+		podAnnotations[credentialsHashAnnotation] = credentials.SuperUserHash + credentials.ReplicationUserHash
+	}
+
+	volumeMounts := []coreV1.VolumeMount{
+		{
+			Name:      "pgdata",
+			MountPath: "/home/postgres/pgdata",
+		},
+		{
+			Name:      "patroni-config",
+			MountPath: patroniConfigMountPath,
+			ReadOnly:  true,
+		},
+	}
+	var storage v1alpha1.StorageSpec
+	if pCluster.PatroniClusterSpec.Storage != nil {
+		storage = *pCluster.PatroniClusterSpec.Storage
+	}
+	volumeClaimTemplates := []coreV1.PersistentVolumeClaim{
+		volumeClaimTemplate("pgdata", pClusterName, statefulsetId, storage.VolumeSpec),
+	}
+	if storage.WAL != nil {
+		volumeMounts = append(volumeMounts, coreV1.VolumeMount{
+			Name:      "pgwal",
+			MountPath: defaultPgWalMountPath,
+		})
+		volumeClaimTemplates = append(volumeClaimTemplates, volumeClaimTemplate("pgwal", pClusterName, statefulsetId, *storage.WAL))
 	}
 
 	var replicas int32 = 1
 	var terminationGracePeriodSeconds int64 = 0
 
-	return v1.StatefulSet{
+	sts := v1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      statefulsetId,
 			Namespace: pCluster.Namespace,
@@ -82,17 +132,22 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 			},
 			Template: coreV1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labelSelector,
+					Labels:      labelSelector,
+					Annotations: podAnnotations,
 				},
 				Spec: coreV1.PodSpec{
 					Affinity: &coreV1.Affinity{
+						NodeAffinity:    nodeAffinitySet(member),
 						PodAntiAffinity: &podAffinitySet,
 					},
+					NodeSelector: nodeSelector,
 					Containers: []coreV1.Container{
 						{
-							Name:            "postgres",
+							Name:            postgresContainerName,
 							Image:           pCluster.PatroniClusterSpec.Image,
 							ImagePullPolicy: coreV1.PullIfNotPresent,
+							Command:         []string{"patroni", fmt.Sprintf("%s/%s", patroniConfigMountPath, patroniConfigKey)},
+							Resources:       pCluster.PatroniClusterSpec.Resources,
 							ReadinessProbe: &coreV1.Probe{
 								ProbeHandler: coreV1.ProbeHandler{
 									HTTPGet: &coreV1.HTTPGetAction{
@@ -117,7 +172,7 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 									Protocol:      coreV1.ProtocolTCP,
 								},
 							},
-							Env: []coreV1.EnvVar{
+							Env: append([]coreV1.EnvVar{
 								{
 									Name: "PATRONI_KUBERNETES_POD_IP",
 									ValueFrom: &coreV1.EnvVarSource{
@@ -126,6 +181,14 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 										},
 									},
 								},
+								{
+									Name:  "PATRONI_RESTAPI_CONNECT_ADDRESS",
+									Value: "$(PATRONI_KUBERNETES_POD_IP):8008",
+								},
+								{
+									Name:  "PATRONI_POSTGRESQL_CONNECT_ADDRESS",
+									Value: "$(PATRONI_KUBERNETES_POD_IP):5432",
+								},
 								{
 									Name: "PATRONI_KUBERNETES_NAMESPACE",
 									ValueFrom: &coreV1.EnvVarSource{
@@ -148,19 +211,33 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 								},
 								{
 									Name:  "PATRONI_SUPERUSER_USERNAME",
-									Value: defaultSuperUserName,
+									Value: superUserName(pCluster),
 								},
 								{
-									Name:  "PATRONI_SUPERUSER_PASSWORD",
-									Value: defaultSuperUserPassword,
+									Name: "PATRONI_SUPERUSER_PASSWORD",
+									ValueFrom: &coreV1.EnvVarSource{
+										SecretKeyRef: &coreV1.SecretKeySelector{
+											LocalObjectReference: coreV1.LocalObjectReference{
+												Name: pCluster.PatroniClusterSpec.SuperUserSecretName,
+											},
+											Key: secretPasswordKey,
+										},
+									},
 								},
 								{
 									Name:  "PATRONI_REPLICATION_USERNAME",
-									Value: defaultReplicationUserName,
+									Value: replicationUserName(pCluster),
 								},
 								{
-									Name:  "PATRONI_REPLICATION_PASSWORD",
-									Value: defaultReplicationUserPassword,
+									Name: "PATRONI_REPLICATION_PASSWORD",
+									ValueFrom: &coreV1.EnvVarSource{
+										SecretKeyRef: &coreV1.SecretKeySelector{
+											LocalObjectReference: coreV1.LocalObjectReference{
+												Name: pCluster.PatroniClusterSpec.ReplicationUserSecretName,
+											},
+											Key: secretPasswordKey,
+										},
+									},
 								},
 								{
 									Name:  "PATRONI_SCOPE",
@@ -190,40 +267,95 @@ func generatorStatefulset(indexName string, pCluster *v1alpha1.PatroniCluster) v
 									Name:  "PATRONI_RESTAPI_LISTEN",
 									Value: "0.0.0.0:8008",
 								},
-								// TODO: sync 通过控制器统一设置
-							},
-							VolumeMounts: []coreV1.VolumeMount{
-								{
-									Name:      "pgdata",
-									MountPath: "/home/postgres/pgdata",
+							}, append(backupEnv(pCluster), bootstrapEnv(pCluster)...)...),
+							VolumeMounts: volumeMounts,
+						},
+					},
+					Volumes: []coreV1.Volume{
+						{
+							Name: "patroni-config",
+							VolumeSource: coreV1.VolumeSource{
+								ConfigMap: &coreV1.ConfigMapVolumeSource{
+									LocalObjectReference: coreV1.LocalObjectReference{
+										Name: patroniConfigName(pCluster),
+									},
 								},
 							},
 						},
 					},
 					TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
-					ServiceAccountName:            pCluster.PatroniClusterSpec.ServiceAccount,
+					ServiceAccountName:            serviceAccount,
 				},
 			},
-			VolumeClaimTemplates: []coreV1.PersistentVolumeClaim{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels: map[string]string{
-							"application":    "patroni",
-							"statefulset-id": statefulsetId,
-						},
-						Name: "pgdata",
-					},
-					Spec: coreV1.PersistentVolumeClaimSpec{
-						AccessModes: []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce},
-						Resources: coreV1.ResourceRequirements{
-							Requests: coreV1.ResourceList{
-								coreV1.ResourceStorage: resource.MustParse("5Gi"),
-							},
-						},
-					},
+			VolumeClaimTemplates: volumeClaimTemplates,
+			ServiceName:          fmt.Sprintf("%s-repl", pClusterName),
+		},
+	}
+
+	setLastAppliedTemplate(&sts)
+	return sts
+}
+
+// volumeClaimTemplate 根据数据卷配置生成成员的数据卷模板，未指定的参数使用默认值
+func volumeClaimTemplate(name, pClusterName, statefulsetId string, volume v1alpha1.VolumeSpec) coreV1.PersistentVolumeClaim {
+
+	size := resource.MustParse(defaultVolumeSize)
+	if volume.Size != nil {
+		size = *volume.Size
+	}
+	accessModes := volume.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce}
+	}
+
+	return coreV1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"application":    "patroni",
+				"cluster-name":   pClusterName,
+				"statefulset-id": statefulsetId,
+			},
+			Name: name,
+		},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources: coreV1.ResourceRequirements{
+				Requests: coreV1.ResourceList{
+					coreV1.ResourceStorage: size,
 				},
 			},
-			ServiceName: fmt.Sprintf("%s-repl", pClusterName),
+			StorageClassName: volume.StorageClassName,
+			Selector:         volume.Selector,
 		},
 	}
 }
+
+// setLastAppliedTemplate 记录生成的 Pod 模板，用于和后续期望的模板比较
+// 直接比较线上对象会受到 apiserver 默认值的干扰
+func setLastAppliedTemplate(sts *v1.StatefulSet) {
+	data, err := json.Marshal(sts.Spec.Template)
+	if err != nil {
+		return
+	}
+	if sts.Annotations == nil {
+		sts.Annotations = map[string]string{}
+	}
+	sts.Annotations[lastAppliedTemplateAnnotation] = string(data)
+}
+
+// templateDiff 比较线上 statefulset 最后一次应用的模板和期望的模板，返回差异列表
+func templateDiff(live, desired *v1.StatefulSet) []string {
+
+	lastApplied := coreV1.PodTemplateSpec{}
+	if err := json.Unmarshal([]byte(live.Annotations[lastAppliedTemplateAnnotation]), &lastApplied); err != nil {
+		return []string{"last applied template not found"}
+	}
+
+	// 经过一次序列化，保证两侧的空值表现一致
+	want := coreV1.PodTemplateSpec{}
+	if err := json.Unmarshal([]byte(desired.Annotations[lastAppliedTemplateAnnotation]), &want); err != nil {
+		return []string{err.Error()}
+	}
+
+	return reflectutils.Equal(lastApplied, want)
+}
@@ -0,0 +1,33 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestStatefulsetCommand 成员以挂载的配置文件启动 Patroni，不通过环境变量传递配置文件
+func TestStatefulsetCommand(t *testing.T) {
+
+	sts := generatorStatefulset("a", newTestCluster("a"))
+	container := sts.Spec.Template.Spec.Containers[0]
+
+	if command := fmt.Sprint(container.Command); command != "[patroni /etc/patroni/patroni.yml]" {
+		t.Errorf("expected command [patroni /etc/patroni/patroni.yml], got %s", command)
+	}
+	for _, env := range container.Env {
+		// PATRONI_CONFIGURATION 存在时 Patroni 忽略其他 PATRONI_* 环境变量
+		if env.Name == "PATRONI_CONFIGURATION" || env.Name == "PATRONI_CONFIG_FILE" {
+			t.Errorf("unexpected env %s", env.Name)
+		}
+	}
+
+	mounted := false
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == "patroni-config" && mount.MountPath == patroniConfigMountPath {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Errorf("expected patroni config mounted at %s", patroniConfigMountPath)
+	}
+}
@@ -0,0 +1,57 @@
+package cluster
+
+import (
+	"context"
+	"k8s.io/client-go/rest"
+	clusterv1alpha1 "pgoperator/pkg/apis/cluster/v1alpha1"
+	pgOperatorCli "pgoperator/pkg/client/clientset/versioned"
+)
+
+// statusWriter 通过 status 子资源写回 PatroniCluster 和 PatroniBackup 的状态，
+// 生成的客户端没有 UpdateStatus 方法，CRD 开启子资源后 Update 会忽略状态字段
+type statusWriter interface {
+	UpdateClusterStatus(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error)
+	UpdateBackupStatus(backup *clusterv1alpha1.PatroniBackup) (*clusterv1alpha1.PatroniBackup, error)
+}
+
+type restStatusWriter struct {
+	client rest.Interface
+}
+
+func newStatusWriter(pgOperatorCli pgOperatorCli.Interface) statusWriter {
+	return &restStatusWriter{client: pgOperatorCli.RccpV1alpha1().RESTClient()}
+}
+
+func (w *restStatusWriter) UpdateClusterStatus(pCluster *clusterv1alpha1.PatroniCluster) (*clusterv1alpha1.PatroniCluster, error) {
+
+	result := &clusterv1alpha1.PatroniCluster{}
+	err := w.client.Put().
+		Namespace(pCluster.Namespace).
+		Resource("patroniclusters").
+		Name(pCluster.Name).
+		SubResource("status").
+		Body(pCluster).
+		Do(context.Background()).
+		Into(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (w *restStatusWriter) UpdateBackupStatus(backup *clusterv1alpha1.PatroniBackup) (*clusterv1alpha1.PatroniBackup, error) {
+
+	result := &clusterv1alpha1.PatroniBackup{}
+	err := w.client.Put().
+		Namespace(backup.Namespace).
+		Resource("patronibackups").
+		Name(backup.Name).
+		SubResource("status").
+		Body(backup).
+		Do(context.Background()).
+		Into(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
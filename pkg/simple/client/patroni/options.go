@@ -0,0 +1,47 @@
+package patroni
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"pgoperator/pkg/utils/reflectutils"
+	"time"
+)
+
+type Options struct {
+	// Patroni REST API 请求超时时间
+	// +optional
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout"`
+
+	// 集群健康检查间隔
+	// +optional
+	HealthCheckInterval time.Duration `json:"healthCheckInterval,omitempty" yaml:"healthCheckInterval"`
+}
+
+func NewPatroniOptions() *Options {
+	return &Options{
+		Timeout:             defaultTimeout,
+		HealthCheckInterval: 30 * time.Second,
+	}
+}
+
+func (o *Options) Validate() []error {
+	var errors []error
+	if o.Timeout <= 0 {
+		errors = append(errors, fmt.Errorf("patroni timeout must be greater than 0"))
+	}
+	if o.HealthCheckInterval <= 0 {
+		errors = append(errors, fmt.Errorf("patroni health check interval must be greater than 0"))
+	}
+	return errors
+}
+
+func (o *Options) ApplyTo(options *Options) {
+	reflectutils.Override(options, o)
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet, c *Options) {
+	fs.DurationVar(&o.Timeout, "patroni-timeout", c.Timeout, ""+
+		"Timeout for requests to patroni REST API.")
+	fs.DurationVar(&o.HealthCheckInterval, "patroni-health-check-interval", c.HealthCheckInterval, ""+
+		"Interval of polling patroni members to check cluster health.")
+}
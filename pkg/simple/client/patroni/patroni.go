@@ -0,0 +1,157 @@
+package patroni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultPort Patroni REST API 端口
+	DefaultPort = 8008
+
+	defaultTimeout = 5 * time.Second
+)
+
+// Interface Patroni REST API 客户端，每个客户端对应一个集群成员
+type Interface interface {
+	// Patroni 获取成员自身的状态
+	Patroni(ctx context.Context) (*MemberStatus, error)
+	// Health 成员 PostgreSQL 正常运行时返回 nil
+	Health(ctx context.Context) error
+	// Cluster 获取成员视角下的集群拓扑
+	Cluster(ctx context.Context) (*ClusterInfo, error)
+	// Switchover 将 leader 切换到 candidate
+	Switchover(ctx context.Context, leader, candidate string) error
+	// Failover 将 candidate 提升为 leader，不要求当前 leader 健康
+	Failover(ctx context.Context, candidate string) error
+	// Config 获取集群的动态配置
+	Config(ctx context.Context) (map[string]interface{}, error)
+	// PatchConfig 合并更新集群的动态配置，值为 nil 的键会被删除
+	PatchConfig(ctx context.Context, patch map[string]interface{}) error
+}
+
+// ClientFunc 根据成员地址创建客户端
+type ClientFunc func(host string) Interface
+
+type client struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func NewClient(host string) Interface {
+	return newClient(host, defaultTimeout)
+}
+
+// NewClientFunc 返回使用指定配置创建客户端的函数
+func NewClientFunc(options *Options) ClientFunc {
+	return func(host string) Interface {
+		return newClient(host, options.Timeout)
+	}
+}
+
+func newClient(host string, timeout time.Duration) Interface {
+	return &client{
+		endpoint: fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(DefaultPort))),
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (c *client) Patroni(ctx context.Context) (*MemberStatus, error) {
+	status := &MemberStatus{}
+	if err := c.do(ctx, http.MethodGet, "/patroni", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil)
+}
+
+func (c *client) Cluster(ctx context.Context) (*ClusterInfo, error) {
+	info := &ClusterInfo{}
+	if err := c.do(ctx, http.MethodGet, "/cluster", nil, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *client) Switchover(ctx context.Context, leader, candidate string) error {
+	req := map[string]string{
+		"leader":    leader,
+		"candidate": candidate,
+	}
+	return c.do(ctx, http.MethodPost, "/switchover", req, nil)
+}
+
+func (c *client) Failover(ctx context.Context, candidate string) error {
+	req := map[string]string{
+		"candidate": candidate,
+	}
+	return c.do(ctx, http.MethodPost, "/failover", req, nil)
+}
+
+func (c *client) Config(ctx context.Context) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if err := c.do(ctx, http.MethodGet, "/config", nil, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *client) PatchConfig(ctx context.Context, patch map[string]interface{}) error {
+	return c.do(ctx, http.MethodPatch, "/config", patch, nil)
+}
+
+// do 发送请求，body 和 out 为空时不做 json 编解码
+func (c *client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "request patroni %s %s failed", method, c.endpoint+path)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &StatusError{Code: resp.StatusCode, Message: string(bytes.TrimSpace(data))}
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,243 @@
+package patroni
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakePatroni 模拟单个成员的 Patroni REST API，记录收到的请求
+type fakePatroni struct {
+	member  *MemberStatus
+	cluster *ClusterInfo
+	config  map[string]interface{}
+	healthy bool
+
+	requests []string
+	bodies   map[string]map[string]interface{}
+}
+
+func (f *fakePatroni) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	var body map[string]interface{}
+	if data, _ := ioutil.ReadAll(r.Body); len(data) != 0 {
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected content type", http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.bodies[r.Method+" "+r.URL.Path] = body
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /patroni":
+		json.NewEncoder(w).Encode(f.member)
+	case "GET /health":
+		if !f.healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	case "GET /cluster":
+		json.NewEncoder(w).Encode(f.cluster)
+	case "POST /switchover":
+		if body["leader"] != f.member.Patroni.Scope+"-0" {
+			http.Error(w, "leader name does not match", http.StatusPreconditionFailed)
+			return
+		}
+		w.Write([]byte("Successfully switched over"))
+	case "GET /config":
+		json.NewEncoder(w).Encode(f.config)
+	case "PATCH /config":
+		for k, v := range body {
+			if v == nil {
+				delete(f.config, k)
+				continue
+			}
+			f.config[k] = v
+		}
+		json.NewEncoder(w).Encode(f.config)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakePatroni(t *testing.T) (*fakePatroni, Interface) {
+
+	f := &fakePatroni{
+		member: &MemberStatus{State: StateRunning, Role: "master"},
+		cluster: &ClusterInfo{
+			Scope: "pg",
+			Members: []Member{
+				{Name: "pg-0", Role: RoleLeader, State: StateRunning, Timeline: 2, Lag: 0.0},
+				{Name: "pg-1", Role: RoleReplica, State: StateRunning, Timeline: 2, Lag: 1024.0},
+				{Name: "pg-2", Role: RoleReplica, State: "streaming", Timeline: 2, Lag: "unknown"},
+			},
+		},
+		config:  map[string]interface{}{"ttl": 30.0, "loop_wait": 10.0},
+		healthy: true,
+		bodies:  map[string]map[string]interface{}{},
+	}
+	f.member.Patroni.Scope = "pg"
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, &client{endpoint: server.URL, httpClient: server.Client()}
+}
+
+func TestPatroni(t *testing.T) {
+
+	f, cli := newFakePatroni(t)
+	f.member.Xlog.Location = 67108864
+
+	status, err := cli.Patroni(context.Background())
+	if err != nil {
+		t.Fatalf("get member status failed: %v", err)
+	}
+	if !status.IsLeader() || status.IsStandbyLeader() || status.State != StateRunning {
+		t.Errorf("unexpected member status %+v", status)
+	}
+	if status.Xlog.Location != 67108864 {
+		t.Errorf("expected xlog location 67108864, got %d", status.Xlog.Location)
+	}
+
+	for role, leader := range map[string]bool{"master": true, "primary": true, RoleReplica: false, RoleStandbyLeader: false} {
+		f.member.Role = role
+		status, err := cli.Patroni(context.Background())
+		if err != nil {
+			t.Fatalf("get member status failed: %v", err)
+		}
+		if status.IsLeader() != leader {
+			t.Errorf("role %s: expected IsLeader %v", role, leader)
+		}
+		if status.IsStandbyLeader() != (role == RoleStandbyLeader) {
+			t.Errorf("role %s: unexpected IsStandbyLeader", role)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+
+	f, cli := newFakePatroni(t)
+
+	if err := cli.Health(context.Background()); err != nil {
+		t.Errorf("expected healthy member, got %v", err)
+	}
+
+	f.healthy = false
+	err := cli.Health(context.Background())
+	statusErr, ok := err.(*StatusError)
+	if !ok {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	if statusErr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code 503, got %d", statusErr.Code)
+	}
+}
+
+func TestCluster(t *testing.T) {
+
+	_, cli := newFakePatroni(t)
+
+	info, err := cli.Cluster(context.Background())
+	if err != nil {
+		t.Fatalf("get cluster failed: %v", err)
+	}
+	if len(info.Members) != 3 {
+		t.Fatalf("expected 3 members, got %d", len(info.Members))
+	}
+	if leader := info.Leader(); leader == nil || leader.Name != "pg-0" {
+		t.Errorf("expected leader pg-0, got %+v", leader)
+	}
+	if lag := info.Members[1].LagBytes(); lag == nil || *lag != 1024 {
+		t.Errorf("expected lag 1024 of pg-1, got %v", lag)
+	}
+	if lag := info.Members[2].LagBytes(); lag != nil {
+		t.Errorf("expected unknown lag of pg-2, got %d", *lag)
+	}
+
+	info.Members[0].Role = RoleStandbyLeader
+	if leader := info.Leader(); leader == nil || leader.Name != "pg-0" {
+		t.Errorf("expected standby leader pg-0, got %+v", leader)
+	}
+	info.Members[0].Role = RoleReplica
+	if leader := info.Leader(); leader != nil {
+		t.Errorf("expected no leader, got %+v", leader)
+	}
+}
+
+func TestSwitchover(t *testing.T) {
+
+	f, cli := newFakePatroni(t)
+
+	if err := cli.Switchover(context.Background(), "pg-0", "pg-1"); err != nil {
+		t.Fatalf("switchover failed: %v", err)
+	}
+	expected := map[string]interface{}{"leader": "pg-0", "candidate": "pg-1"}
+	if body := f.bodies["POST /switchover"]; !reflect.DeepEqual(body, expected) {
+		t.Errorf("expected switchover request %v, got %v", expected, body)
+	}
+
+	err := cli.Switchover(context.Background(), "pg-1", "pg-2")
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 StatusError, got %v", err)
+	}
+	if statusErr.Message != "leader name does not match" {
+		t.Errorf("unexpected error message %q", statusErr.Message)
+	}
+}
+
+func TestPatchConfig(t *testing.T) {
+
+	f, cli := newFakePatroni(t)
+
+	patch := map[string]interface{}{
+		"ttl":             60,
+		"loop_wait":       nil,
+		"standby_cluster": map[string]interface{}{"host": "pg-source"},
+	}
+	if err := cli.PatchConfig(context.Background(), patch); err != nil {
+		t.Fatalf("patch config failed: %v", err)
+	}
+
+	// 值为 nil 的键需要以 null 发送，由 Patroni 删除
+	body := f.bodies["PATCH /config"]
+	if v, ok := body["loop_wait"]; !ok || v != nil {
+		t.Errorf("expected loop_wait to be sent as null, got %v", body)
+	}
+
+	config, err := cli.Config(context.Background())
+	if err != nil {
+		t.Fatalf("get config failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"ttl":             60.0,
+		"standby_cluster": map[string]interface{}{"host": "pg-source"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected config %v, got %v", expected, config)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	cli := &client{endpoint: server.URL, httpClient: server.Client()}
+	server.Close()
+
+	if _, err := cli.Patroni(context.Background()); err == nil {
+		t.Error("expected error from unreachable member")
+	}
+	if _, ok := cli.Health(context.Background()).(*StatusError); ok {
+		t.Error("expected connection error instead of StatusError")
+	}
+}
@@ -0,0 +1,108 @@
+package patroni
+
+import "fmt"
+
+// 成员角色
+const (
+	RoleLeader        = "leader"
+	RoleReplica       = "replica"
+	RoleSyncStandby   = "sync_standby"
+	RoleStandbyLeader = "standby_leader"
+)
+
+// 成员状态
+const (
+	StateRunning     = "running"
+	StateStartFailed = "start failed"
+)
+
+// replica 复制状态
+const ReplicationStreaming = "streaming"
+
+// MemberStatus GET /patroni 返回的成员状态
+type MemberStatus struct {
+	State          string `json:"state"`
+	Role           string `json:"role"`
+	ServerVersion  int    `json:"server_version,omitempty"`
+	Timeline       int64  `json:"timeline,omitempty"`
+	PendingRestart bool   `json:"pending_restart,omitempty"`
+	// replica 的复制状态，例如 streaming，Patroni 3.0 之前的版本不返回
+	ReplicationState string `json:"replication_state,omitempty"`
+	// WAL 位置，leader 返回 location，replica 和 standby leader 返回 received_location 和 replayed_location
+	Xlog struct {
+		Location         int64 `json:"location,omitempty"`
+		ReceivedLocation int64 `json:"received_location,omitempty"`
+		ReplayedLocation int64 `json:"replayed_location,omitempty"`
+	} `json:"xlog"`
+	Patroni struct {
+		Version string `json:"version,omitempty"`
+		Scope   string `json:"scope,omitempty"`
+	} `json:"patroni"`
+}
+
+// IsLeader 成员是否为主库，不同版本的 Patroni 使用 master 或 primary
+func (s *MemberStatus) IsLeader() bool {
+	return s.Role == "master" || s.Role == "primary"
+}
+
+// Streaming replica 正在从上游流复制，旧版本 Patroni 不返回复制状态时以 running 状态代替
+func (s *MemberStatus) Streaming() bool {
+	if s.ReplicationState != "" {
+		return s.ReplicationState == ReplicationStreaming
+	}
+	return s.State == StateRunning
+}
+
+// IsStandbyLeader 成员是否为备库集群中从远端复制的 standby leader
+func (s *MemberStatus) IsStandbyLeader() bool {
+	return s.Role == RoleStandbyLeader
+}
+
+// ClusterInfo GET /cluster 返回的集群拓扑
+type ClusterInfo struct {
+	Scope   string   `json:"scope,omitempty"`
+	Members []Member `json:"members"`
+}
+
+type Member struct {
+	Name     string      `json:"name"`
+	Role     string      `json:"role"`
+	State    string      `json:"state"`
+	ApiUrl   string      `json:"api_url,omitempty"`
+	Host     string      `json:"host,omitempty"`
+	Port     int         `json:"port,omitempty"`
+	Timeline int64       `json:"timeline,omitempty"`
+	Lag      interface{} `json:"lag,omitempty"`
+	// 修改的参数需要重启才能生效
+	PendingRestart bool `json:"pending_restart,omitempty"`
+}
+
+// Leader 返回集群当前 leader，没有 leader 时返回 nil
+func (c *ClusterInfo) Leader() *Member {
+	for i := range c.Members {
+		if c.Members[i].Role == RoleLeader || c.Members[i].Role == RoleStandbyLeader {
+			return &c.Members[i]
+		}
+	}
+	return nil
+}
+
+// LagBytes 复制延迟，Patroni 无法计算延迟时返回 unknown
+func (m *Member) LagBytes() *int64 {
+	lag, ok := m.Lag.(float64)
+	if !ok {
+		return nil
+	}
+	bytes := int64(lag)
+	return &bytes
+}
+
+// StatusError Patroni 返回非 2xx 状态码
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("patroni response %d: %s", e.Code, e.Message)
+}
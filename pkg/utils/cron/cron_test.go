@@ -0,0 +1,103 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+
+	// 2024-01-01 为星期一
+	tests := []struct {
+		name     string
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"every minute", "* * * * *", date(2024, 1, 1, 10, 7), date(2024, 1, 1, 10, 8)},
+		{"strictly after", "*/15 * * * *", date(2024, 1, 1, 10, 15).Add(30 * time.Second), date(2024, 1, 1, 10, 30)},
+		{"minute step", "*/15 * * * *", date(2024, 1, 1, 10, 7), date(2024, 1, 1, 10, 15)},
+		{"hour step", "0 */6 * * *", date(2024, 1, 1, 7, 0), date(2024, 1, 1, 12, 0)},
+		{"value with step", "5/20 * * * *", date(2024, 1, 1, 10, 46), date(2024, 1, 1, 11, 5)},
+		{"range with step", "10-20/5 * * * *", date(2024, 1, 1, 10, 16), date(2024, 1, 1, 10, 20)},
+		{"range with step wraps to next hour", "10-20/5 * * * *", date(2024, 1, 1, 10, 21), date(2024, 1, 1, 11, 10)},
+		{"list", "0 0 1,15 * *", date(2024, 1, 2, 0, 0), date(2024, 1, 15, 0, 0)},
+		{"weekdays", "30 9 * * 1-5", date(2024, 1, 6, 10, 0), date(2024, 1, 8, 9, 30)},
+		{"sunday as 7", "0 0 * * 7", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"range ending with 7", "0 0 * * 6-7", date(2024, 1, 1, 0, 0), date(2024, 1, 6, 0, 0)},
+		{"month", "0 0 1 6 *", date(2024, 1, 1, 0, 0), date(2024, 6, 1, 0, 0)},
+		{"next year", "0 0 1 1 *", date(2024, 1, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+		// 日期和星期都被限制时满足其一即可
+		{"day of month or day of week", "0 0 13 * 5", date(2024, 1, 1, 0, 0), date(2024, 1, 5, 0, 0)},
+		{"day of month when day of week is star", "0 0 13 * *", date(2024, 1, 1, 0, 0), date(2024, 1, 13, 0, 0)},
+		// 以 * 开头的字段不受 OR 规则影响，需要同时满足
+		{"day of month step and day of week", "0 0 */10 * 1", date(2024, 1, 2, 0, 0), date(2024, 3, 11, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"daily descriptor", "@daily", date(2024, 1, 1, 23, 59), date(2024, 1, 2, 0, 0)},
+		{"weekly descriptor", "@weekly", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"hourly descriptor", "@hourly", date(2024, 1, 1, 10, 0), date(2024, 1, 1, 11, 0)},
+		{"never", "0 0 30 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("parse %q failed: %v", tt.spec, err)
+			}
+			if next := s.Next(tt.from); !next.Equal(tt.expected) {
+				t.Errorf("expected next of %q after %s to be %s, got %s", tt.spec, tt.from, tt.expected, next)
+			}
+		})
+	}
+}
+
+// TestNextLocation 按照 t 所在的时区计算
+func TestNextLocation(t *testing.T) {
+
+	loc := time.FixedZone("UTC+8", 8*3600)
+	s, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	next := s.Next(time.Date(2024, 1, 1, 3, 0, 0, 0, loc))
+	if expected := time.Date(2024, 1, 2, 2, 0, 0, 0, loc); !next.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, next)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/a * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"-1 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"50/5-10 * * * *",
+	}
+
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error parsing %q", spec)
+		}
+	}
+}